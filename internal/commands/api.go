@@ -0,0 +1,398 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/onuse/tasks/internal/store"
+)
+
+// maxRequestBody limits the size of JSON request bodies
+const maxRequestBody = 1 << 20
+
+// writeMu serializes API writes so concurrent requests can't interleave
+// manifest, task and index updates
+var writeMu sync.Mutex
+
+// apiError is the JSON body of a failed API request
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// linkRequest is the body of POST /api/task/{id}/links
+type linkRequest struct {
+	TargetID      int    `json:"target_id"`
+	Type          string `json:"type"`
+	Label         string `json:"label"`
+	Bidirectional bool   `json:"bidirectional"`
+}
+
+// createRequest is the body of POST /api/tasks
+type createRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// tagRequest is the body of POST /api/task/{id}/tags
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+// mergeRequest is the body of POST /api/task/{id}/merge
+type mergeRequest struct {
+	TargetID int `json:"target_id"`
+}
+
+// registerWriteAPI registers the mutating API endpoints. bind is the address
+// the server listens on (see guardWrite).
+func registerWriteAPI(mux *http.ServeMux, s *store.Store, bind string) {
+	mux.HandleFunc("POST /api/tasks", guardWrite(bind, func(w http.ResponseWriter, r *http.Request) {
+		createTaskAPI(w, r, s)
+	}))
+	mux.HandleFunc("PATCH /api/task/{id}", guardWrite(bind, func(w http.ResponseWriter, r *http.Request) {
+		updateTaskAPI(w, r, s)
+	}))
+	mux.HandleFunc("POST /api/task/{id}/links", guardWrite(bind, func(w http.ResponseWriter, r *http.Request) {
+		linkTaskAPI(w, r, s)
+	}))
+	mux.HandleFunc("DELETE /api/task/{id}/links/{target}", guardWrite(bind, func(w http.ResponseWriter, r *http.Request) {
+		unlinkTaskAPI(w, r, s)
+	}))
+	mux.HandleFunc("POST /api/task/{id}/tags", guardWrite(bind, func(w http.ResponseWriter, r *http.Request) {
+		tagTaskAPI(w, r, s)
+	}))
+	mux.HandleFunc("DELETE /api/task/{id}/tags/{name}", guardWrite(bind, func(w http.ResponseWriter, r *http.Request) {
+		untagTaskAPI(w, r, s)
+	}))
+	mux.HandleFunc("POST /api/task/{id}/merge", guardWrite(bind, func(w http.ResponseWriter, r *http.Request) {
+		mergeTaskAPI(w, r, s)
+	}))
+}
+
+// guardWrite rejects write requests that another web site could have made
+// the browser send. A page can POST a form to 127.0.0.1 without asking, and
+// with DNS rebinding it can even read the answers, so requests must come
+// from this server's own pages (Origin) and name the address it is bound to
+// (Host). Requests without an Origin, such as curl's, only need the Host.
+func guardWrite(bind string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hostMatchesBind(r.Host, bind) {
+			writeAPIError(w, newError(ErrCodeForbidden, "host '%s' does not match the server address", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !strings.EqualFold(u.Host, r.Host) {
+				writeAPIError(w, newError(ErrCodeForbidden, "cross-origin write from '%s' refused", origin))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// hostMatchesBind reports whether a request's Host header names the address
+// the server listens on. A loopback server only answers to localhost and
+// loopback IPs; one bound to every interface can't tell its names apart and
+// accepts any.
+func hostMatchesBind(host, bind string) bool {
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	name = strings.Trim(name, "[]")
+
+	switch {
+	case bind == "" || bind == "0.0.0.0" || bind == "::":
+		return true
+	case isLoopback(bind):
+		return isLoopback(name)
+	default:
+		return strings.EqualFold(name, bind)
+	}
+}
+
+func createTaskAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	var req createRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	t, err := createTask(s, req.Title, req.Description)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(t.ID, t.Updated))
+	writeAPIJSON(w, http.StatusCreated, CreateResult{ID: t.ID, Task: t})
+}
+
+func updateTaskAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	id, err := pathTaskID(r, "id")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	var changes TaskChanges
+	if err := decodeJSONBody(w, r, &changes); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if err := checkIfMatch(r, s, id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	t, fieldChanges, err := updateTask(s, id, changes)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(t.ID, t.Updated))
+	writeAPIJSON(w, http.StatusOK, UpdateResult{ID: id, Changes: fieldChanges, Task: t})
+}
+
+func linkTaskAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	id, err := pathTaskID(r, "id")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	var req linkRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if err := checkIfMatch(r, s, id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	links, err := linkTasks(s, id, req.TargetID, req.Type, req.Label, req.Bidirectional)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	setTaskETag(w, s, id)
+	writeAPIJSON(w, http.StatusCreated, LinkResult{Links: links})
+}
+
+func unlinkTaskAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	id, err := pathTaskID(r, "id")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	targetID, err := pathTaskID(r, "target")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	bidirectional := query.Get("bidirectional") == "true"
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if err := checkIfMatch(r, s, id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	removed, err := unlinkTasks(s, id, targetID, query.Get("type"), bidirectional)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	setTaskETag(w, s, id)
+	writeAPIJSON(w, http.StatusOK, UnlinkResult{Removed: removed})
+}
+
+func tagTaskAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	id, err := pathTaskID(r, "id")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	var req tagRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if err := checkIfMatch(r, s, id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	result, err := tagTask(s, id, req.Name)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	setTaskETag(w, s, id)
+	status := http.StatusOK
+	if result.Changed {
+		status = http.StatusCreated
+	}
+	writeAPIJSON(w, status, result)
+}
+
+func untagTaskAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	id, err := pathTaskID(r, "id")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if err := checkIfMatch(r, s, id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	result, err := untagTask(s, id, r.PathValue("name"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	setTaskETag(w, s, id)
+	writeAPIJSON(w, http.StatusOK, result)
+}
+
+func mergeTaskAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	id, err := pathTaskID(r, "id")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	var req mergeRequest
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if err := checkIfMatch(r, s, id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	result, err := mergeTasks(s, id, req.TargetID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	setTaskETag(w, s, id)
+	writeAPIJSON(w, http.StatusOK, result)
+}
+
+// pathTaskID parses a task ID from a path wildcard
+func pathTaskID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, invalidArgf("invalid task ID '%s'", r.PathValue(name))
+	}
+	return id, nil
+}
+
+// decodeJSONBody decodes a JSON request body into v, rejecting unknown fields.
+// The body must be sent as application/json: browsers only send that type
+// cross-site after a CORS preflight, which this server never grants.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return newError(ErrCodeUnsupportedMediaType, "request body must be application/json")
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return invalidArgf("request body is empty")
+		}
+		return invalidArgf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// httpStatusForError maps a command error code to an HTTP status
+func httpStatusForError(code string) int {
+	switch code {
+	case ErrCodeInvalidArgument, ErrCodeNoChanges:
+		return http.StatusBadRequest
+	case ErrCodeUnauthorized:
+		return http.StatusUnauthorized
+	case ErrCodeForbidden:
+		return http.StatusForbidden
+	case ErrCodeUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case ErrCodePreconditionRequired:
+		return http.StatusPreconditionRequired
+	case ErrCodeNotFound:
+		return http.StatusNotFound
+	case ErrCodeConflict, ErrCodeAlreadyExists, ErrCodeWIPLimit:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, err error) {
+	cmdErr := classifyError(err)
+	writeAPIJSON(w, httpStatusForError(cmdErr.Code), apiError{
+		Error: apiErrorDetail{Code: cmdErr.Code, Message: cmdErr.Message},
+	})
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteAPIRefusesCrossSiteRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		bind        string
+		host        string
+		origin      string
+		contentType string
+		wantStatus  int
+	}{
+		{"same-origin JSON", "127.0.0.1", "127.0.0.1:8080", "http://127.0.0.1:8080", "application/json", http.StatusCreated},
+		{"script without origin", "127.0.0.1", "localhost:8080", "", "application/json; charset=utf-8", http.StatusCreated},
+		{"cross-site form post", "127.0.0.1", "127.0.0.1:8080", "https://evil.example", "text/plain", http.StatusForbidden},
+		{"text/plain from the page itself", "127.0.0.1", "127.0.0.1:8080", "http://127.0.0.1:8080", "text/plain", http.StatusUnsupportedMediaType},
+		{"cross-site JSON", "127.0.0.1", "127.0.0.1:8080", "https://evil.example", "application/json", http.StatusForbidden},
+		{"opaque origin", "127.0.0.1", "127.0.0.1:8080", "null", "application/json", http.StatusForbidden},
+		{"DNS rebinding", "127.0.0.1", "evil.example:8080", "http://evil.example:8080", "application/json", http.StatusForbidden},
+		{"other host of a specific bind", "192.168.1.5", "10.0.0.1:8080", "", "application/json", http.StatusForbidden},
+		{"any host of a wildcard bind", "0.0.0.0", "tasks.lan:8080", "http://tasks.lan:8080", "application/json", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			mux := http.NewServeMux()
+			registerWriteAPI(mux, s, tt.bind)
+
+			req := httptest.NewRequest("POST", "/api/tasks", strings.NewReader(`{"title": "From the web"}`))
+			req.Host = tt.host
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			index, err := s.ReadIndex()
+			if err != nil {
+				t.Fatalf("ReadIndex: %v", err)
+			}
+			if created := len(index.Tasks) > 1; created != (tt.wantStatus == http.StatusCreated) {
+				t.Errorf("task created: %v", created)
+			}
+		})
+	}
+}
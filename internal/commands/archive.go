@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// ArchiveResult is the JSON result of the archive and unarchive commands
+type ArchiveResult struct {
+	Tasks  []ArchivedTask `json:"tasks"`
+	Count  int            `json:"count"`
+	DryRun bool           `json:"dry_run,omitempty"`
+}
+
+// ArchivedTask is a task moved into or out of the archive
+type ArchivedTask struct {
+	ID     int         `json:"id"`
+	Title  string      `json:"title"`
+	Status task.Status `json:"status"`
+}
+
+func Archive(args []string) error {
+	fs := newFlagSet("archive")
+	olderFlag := fs.String("older-than", "", "Archive closed (e.g. done, cancelled) tasks finished longer ago than this (e.g. 90d, 12w, 48h)")
+	dryRunFlag := fs.Bool("dry-run", false, "Show what would be archived without moving anything")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if (*olderFlag == "") == (fs.NArg() == 0) {
+		return invalidArgf("usage: task archive --older-than AGE [--dry-run], or task archive <id>...")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	var candidates []*task.Task
+	if *olderFlag != "" {
+		age, err := parseAge(*olderFlag)
+		if err != nil {
+			return err
+		}
+		if candidates, err = finishedBefore(s, time.Now().Add(-age)); err != nil {
+			return err
+		}
+	} else {
+		seen := map[int]bool{}
+		for _, arg := range fs.Args() {
+			id, err := parseTaskID(arg, "task ID")
+			if err != nil {
+				return err
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if s.IsArchived(id) {
+				return invalidArgf("task #%d is already archived", id)
+			}
+			t, err := s.ReadTask(id)
+			if err != nil {
+				return err
+			}
+			if !task.IsClosedStatus(t.Status) {
+				return invalidArgf("task #%d is %s; only tasks in a closed status (such as done or cancelled) can be archived", id, t.Status)
+			}
+			candidates = append(candidates, t)
+		}
+	}
+
+	result := ArchiveResult{Tasks: []ArchivedTask{}, DryRun: *dryRunFlag}
+	var events []task.SessionEvent
+	for _, t := range candidates {
+		if !*dryRunFlag {
+			if err := s.ArchiveTask(t.ID); err != nil {
+				return err
+			}
+			events = append(events, task.SessionEvent{TaskID: t.ID, Action: task.ActionArchive})
+		}
+		result.Tasks = append(result.Tasks, ArchivedTask{ID: t.ID, Title: t.Title, Status: t.Status})
+	}
+	result.Count = len(result.Tasks)
+
+	if len(events) > 0 {
+		if err := s.RebuildIndex(); err != nil {
+			return err
+		}
+		if err := recordActivity(s, events...); err != nil {
+			return err
+		}
+		for i, t := range candidates {
+			notifyWebhooks(s, EventTaskArchived, t, t, result.Tasks[i])
+		}
+	}
+
+	if jsonOutput {
+		return writeResult("archive", result)
+	}
+
+	verb := "Archived"
+	if *dryRunFlag {
+		verb = "Would archive"
+	}
+	fmt.Printf("%s %d task(s)\n", verb, result.Count)
+	for _, t := range result.Tasks {
+		fmt.Printf("  #%-4d [%-9s] %s\n", t.ID, t.Status, t.Title)
+	}
+	return nil
+}
+
+func Unarchive(args []string) error {
+	fs := newFlagSet("unarchive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		return invalidArgf("usage: task unarchive <id>...")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	// Check every ID before moving anything, so a bad one leaves the
+	// archive as it was
+	var restored []*task.Task
+	seen := map[int]bool{}
+	for _, arg := range fs.Args() {
+		id, err := parseTaskID(arg, "task ID")
+		if err != nil {
+			return err
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if !s.IsArchived(id) {
+			return notFoundf("task #%d is not archived", id)
+		}
+
+		t, err := s.ReadTask(id)
+		if err != nil {
+			return err
+		}
+		restored = append(restored, t)
+	}
+
+	result := ArchiveResult{Tasks: []ArchivedTask{}}
+	var events []task.SessionEvent
+	for _, t := range restored {
+		if err := s.UnarchiveTask(t.ID); err != nil {
+			return err
+		}
+		events = append(events, task.SessionEvent{TaskID: t.ID, Action: task.ActionUnarchive})
+		result.Tasks = append(result.Tasks, ArchivedTask{ID: t.ID, Title: t.Title, Status: t.Status})
+	}
+	result.Count = len(result.Tasks)
+
+	if err := s.RebuildIndex(); err != nil {
+		return err
+	}
+	if err := recordActivity(s, events...); err != nil {
+		return err
+	}
+	for i, t := range restored {
+		notifyWebhooks(s, EventTaskUnarchived, t, t, result.Tasks[i])
+	}
+
+	if jsonOutput {
+		return writeResult("unarchive", result)
+	}
+
+	for _, t := range result.Tasks {
+		fmt.Printf("Restored task #%d from the archive: %s\n", t.ID, t.Title)
+	}
+	return nil
+}
+
+// finishedBefore returns the tasks in a closed status that were completed,
+// or for tasks without a completion time last updated, before cutoff
+func finishedBefore(s *store.Store, cutoff time.Time) ([]*task.Task, error) {
+	index, err := s.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*task.Task
+	for _, entry := range index.Tasks {
+		if !task.IsClosedStatus(entry.Status) {
+			continue
+		}
+		t, err := s.ReadTask(entry.ID)
+		if err != nil {
+			continue // Skip tasks we can't read
+		}
+
+		finished := t.Updated
+		if t.Completed != nil {
+			finished = *t.Completed
+		}
+		if finished.Before(cutoff) {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+// parseAge parses an age such as "90d" or "12w", or any Go duration ("36h")
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, invalidArgf("invalid age '%s' (e.g. 90d, 12w, 48h)", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, invalidArgf("invalid age '%s' (e.g. 90d, 12w, 48h)", s)
+	}
+	return d, nil
+}
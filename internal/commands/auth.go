@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// authCookie holds the browser session after a URL token has been exchanged
+const authCookie = "task_session"
+
+// serveAuth guards the web server. Clients authenticate with the bearer
+// token in an Authorization header, or by opening a URL carrying a token
+// (?token=...), which is exchanged for a session cookie. A generated login
+// token only works once. The feeds also accept a feed token, which grants
+// nothing else.
+type serveAuth struct {
+	mu         sync.Mutex
+	bearer     string // Static token from --token; may be empty
+	loginToken string // Generated one-time URL token; cleared once used
+	feedToken  string // Token for feedPaths only, from --feed-token or generated
+	session    string // Cookie value issued after a URL token exchange
+}
+
+// newServeAuth creates the server's auth state. With generate set, a
+// one-time login token is created for the browser URL. Without a bearer
+// token, feeds could not be subscribed to at all, so a feed token is
+// generated unless one was given.
+func newServeAuth(bearer, feedToken string, generate bool) (*serveAuth, error) {
+	session, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &serveAuth{bearer: bearer, feedToken: feedToken, session: session}
+	if generate {
+		if a.loginToken, err = randomToken(); err != nil {
+			return nil, err
+		}
+		if a.bearer == "" && a.feedToken == "" {
+			if a.feedToken, err = randomToken(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// enabled reports whether requests need to authenticate at all
+func (a *serveAuth) enabled() bool {
+	return a.bearer != "" || a.loginToken != ""
+}
+
+// middleware rejects unauthenticated requests with 401
+func (a *serveAuth) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" {
+			if feedPaths[r.URL.Path] && a.feedAuthorized(token) {
+				next.ServeHTTP(w, r) // No cookie or redirect; see feedPaths
+				return
+			}
+			if !a.redeemURLToken(token) {
+				writeAPIError(w, newError(ErrCodeUnauthorized, "invalid or already used token"))
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     authCookie,
+				Value:    a.session,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+
+			// Drop the token from the address bar
+			query := r.URL.Query()
+			query.Del("token")
+			target := r.URL.Path
+			if len(query) > 0 {
+				target += "?" + query.Encode()
+			}
+			http.Redirect(w, r, target, http.StatusSeeOther)
+			return
+		}
+
+		if !a.authorized(r) {
+			writeAPIError(w, newError(ErrCodeUnauthorized, "authentication required"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized checks the Authorization header and the session cookie
+func (a *serveAuth) authorized(r *http.Request) bool {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return a.bearer != "" && tokensEqual(strings.TrimPrefix(header, "Bearer "), a.bearer)
+	}
+
+	cookie, err := r.Cookie(authCookie)
+	return err == nil && tokensEqual(cookie.Value, a.session)
+}
+
+// feedAuthorized checks a ?token= on one of the feedPaths
+func (a *serveAuth) feedAuthorized(token string) bool {
+	return (a.bearer != "" && tokensEqual(token, a.bearer)) ||
+		(a.feedToken != "" && tokensEqual(token, a.feedToken))
+}
+
+// redeemURLToken accepts the bearer token, or the one-time login token which
+// is invalidated on first use
+func (a *serveAuth) redeemURLToken(token string) bool {
+	if a.bearer != "" && tokensEqual(token, a.bearer) {
+		return true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.loginToken != "" && tokensEqual(token, a.loginToken) {
+		a.loginToken = ""
+		return true
+	}
+	return false
+}
+
+// tokensEqual compares tokens in constant time
+func tokensEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// randomToken returns 32 random bytes, hex encoded
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,53 +2,68 @@ package commands
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
-	"os"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/onuse/tasks/internal/store"
 	"github.com/onuse/tasks/internal/task"
 )
 
 type ContextOutput struct {
 	Next               []ContextTask `json:"next"`
 	Active             []ContextTask `json:"active"`
+	OtherOpen          []ContextTask `json:"other_open"` // Open statuses besides backlog, next and active
 	RecentlyCompleted  []ContextTask `json:"recently_completed"`
+	OpenQuestions      []ContextNote `json:"open_questions"`
 	Summary            Summary       `json:"summary"`
+	WIPLimits          []WIPUsage    `json:"wip_limits,omitempty"`
+}
+
+type ContextNote struct {
+	TaskID    int    `json:"task_id"`
+	TaskTitle string `json:"task_title"`
+	Author    string `json:"author"`
+	Text      string `json:"text"`
 }
 
 type ContextTask struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Completed string `json:"completed,omitempty"`
+	ID        int         `json:"id"`
+	Title     string      `json:"title"`
+	Status    task.Status `json:"status,omitempty"` // In other_open and recently_completed
+	Completed string      `json:"completed,omitempty"`
 }
 
 type Summary struct {
-	Total     int `json:"total"`
-	Next      int `json:"next"`
-	Active    int `json:"active"`
-	Backlog   int `json:"backlog"`
-	Done      int `json:"done"`
-	Cancelled int `json:"cancelled"`
+	Total     int           `json:"total"`
+	Next      int           `json:"next"`
+	Active    int           `json:"active"`
+	Backlog   int           `json:"backlog"`
+	Done      int           `json:"done"`
+	Cancelled int           `json:"cancelled"`
+	Statuses  []StatusCount `json:"statuses"` // Every workflow status, in board order
+}
+
+// StatusCount is the number of tasks in a status
+type StatusCount struct {
+	Status   task.Status `json:"status"`
+	Category string      `json:"category"`
+	Count    int         `json:"count"`
 }
 
 func Context(args []string) error {
 	// Parse flags
-	fs := flag.NewFlagSet("context", flag.ExitOnError)
+	fs := newFlagSet("context")
 	formatFlag := fs.String("format", "text", "Output format (text, json)")
-	fs.Parse(args)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	s, err := openStore()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
 	}
 
-	s := store.New(rootDir)
-
 	// Read index
 	index, err := s.ReadIndex()
 	if err != nil {
@@ -58,11 +73,14 @@ func Context(args []string) error {
 	// Organize tasks
 	var next []task.IndexEntry
 	var active []task.IndexEntry
+	var otherOpen []task.IndexEntry
 	var completed []task.IndexEntry
 	summary := Summary{}
+	counts := map[task.Status]int{}
 
 	for _, entry := range index.Tasks {
 		summary.Total++
+		counts[entry.Status]++
 
 		switch entry.Status {
 		case task.StatusNext:
@@ -75,36 +93,73 @@ func Context(args []string) error {
 			summary.Backlog++
 		case task.StatusDone:
 			summary.Done++
-			completed = append(completed, entry)
 		case task.StatusCancelled:
 			summary.Cancelled++
 		}
+
+		// Statuses from a custom workflow, and blocked, are listed with
+		// their status; every closed status counts as completed
+		switch {
+		case task.IsClosedStatus(entry.Status):
+			completed = append(completed, entry)
+		case entry.Status != task.StatusBacklog && entry.Status != task.StatusNext && entry.Status != task.StatusActive:
+			otherOpen = append(otherOpen, entry)
+		}
+	}
+	sort.SliceStable(otherOpen, func(i, j int) bool {
+		return statusRank(otherOpen[i].Status) < statusRank(otherOpen[j].Status)
+	})
+
+	for _, status := range task.CurrentWorkflow().Statuses {
+		summary.Statuses = append(summary.Statuses, StatusCount{
+			Status:   status.Name,
+			Category: status.Category,
+			Count:    counts[status.Name],
+		})
 	}
 
-	// Get recently completed (last 7 days)
+	// Get recently completed (last 7 days), oldest first
 	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
 	var recentCompleted []task.IndexEntry
 	for _, entry := range completed {
-		if entry.Updated.After(sevenDaysAgo) {
+		if completedAt(entry).After(sevenDaysAgo) {
 			recentCompleted = append(recentCompleted, entry)
 		}
 	}
+	sort.SliceStable(recentCompleted, func(i, j int) bool {
+		return completedAt(recentCompleted[i]).Before(completedAt(recentCompleted[j]))
+	})
 
 	// Limit to most recent 5
 	if len(recentCompleted) > 5 {
 		recentCompleted = recentCompleted[len(recentCompleted)-5:]
 	}
 
+	// Questions asked on tasks that are still open
+	questions, err := collectNotes(s, task.NoteKindQuestion, true)
+	if err != nil {
+		return err
+	}
+
+	wip, err := wipUsages(s, index, currentAgent())
+	if err != nil {
+		return err
+	}
+
 	// Output
+	if jsonOutput {
+		return writeResult("context", buildContextOutput(next, active, otherOpen, recentCompleted, questions, summary, wip))
+	}
+
 	switch *formatFlag {
 	case "json":
-		return outputContextJSON(next, active, recentCompleted, summary)
+		return outputContextJSON(next, active, otherOpen, recentCompleted, questions, summary, wip)
 	default:
-		return outputContextText(next, active, recentCompleted, summary)
+		return outputContextText(next, active, otherOpen, recentCompleted, questions, summary, wip)
 	}
 }
 
-func outputContextText(next, active, completed []task.IndexEntry, summary Summary) error {
+func outputContextText(next, active, otherOpen, completed []task.IndexEntry, questions []KindNote, summary Summary, wip []WIPUsage) error {
 	fmt.Println("PROJECT CONTEXT")
 	fmt.Println()
 
@@ -127,27 +182,86 @@ func outputContextText(next, active, completed []task.IndexEntry, summary Summar
 		fmt.Println()
 	}
 
+	if len(otherOpen) > 0 {
+		fmt.Printf("Other Open Tasks (%d):\n", len(otherOpen))
+		for _, t := range otherOpen {
+			fmt.Printf("  #%-4d [%s] %s\n", t.ID, t.Status, t.Title)
+		}
+		fmt.Println()
+	}
+
 	if len(completed) > 0 {
 		fmt.Printf("Recently Completed (%d):\n", len(completed))
 		for _, t := range completed {
-			fmt.Printf("  #%-4d %s (completed %s)\n",
-				t.ID, t.Title, t.Updated.Format("2006-01-02"))
+			verb := "completed"
+			if t.Status != task.StatusDone {
+				verb = string(t.Status)
+			}
+			fmt.Printf("  #%-4d %s (%s %s)\n",
+				t.ID, t.Title, verb, completedAt(t).Format("2006-01-02"))
 		}
 		fmt.Println()
 	}
 
-	fmt.Printf("Total: %d tasks (%d next, %d active, %d backlog, %d done, %d cancelled)\n",
-		summary.Total, summary.Next, summary.Active, summary.Backlog, summary.Done, summary.Cancelled)
+	if len(questions) > 0 {
+		fmt.Printf("Open Questions (%d):\n", len(questions))
+		for _, q := range questions {
+			fmt.Printf("  #%-4d %s (%s)\n", q.TaskID, q.Note.Text, q.Note.Author)
+		}
+		fmt.Println()
+	}
+
+	if len(wip) > 0 {
+		fmt.Println("WIP Limits:")
+		for _, u := range wip {
+			line := fmt.Sprintf("  %s: %d", u.Status, u.Count)
+			if u.Limit > 0 {
+				line += fmt.Sprintf("/%d", u.Limit)
+			}
+			if u.AgentLimit > 0 {
+				line += fmt.Sprintf(" (%s: %d/%d)", u.Agent, u.AgentCount, u.AgentLimit)
+			}
+			fmt.Println(line)
+		}
+		fmt.Println()
+	}
 
+	counts := make([]string, len(summary.Statuses))
+	for i, c := range summary.Statuses {
+		counts[i] = fmt.Sprintf("%d %s", c.Count, c.Status)
+	}
+	fmt.Printf("Total: %d tasks (%s)\n", summary.Total, strings.Join(counts, ", "))
+
+	return nil
+}
+
+func outputContextJSON(next, active, otherOpen, completed []task.IndexEntry, questions []KindNote, summary Summary, wip []WIPUsage) error {
+	data, err := json.MarshalIndent(buildContextOutput(next, active, otherOpen, completed, questions, summary, wip), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
 	return nil
 }
 
-func outputContextJSON(next, active, completed []task.IndexEntry, summary Summary) error {
+func buildContextOutput(next, active, otherOpen, completed []task.IndexEntry, questions []KindNote, summary Summary, wip []WIPUsage) ContextOutput {
 	output := ContextOutput{
 		Next:              make([]ContextTask, len(next)),
 		Active:            make([]ContextTask, len(active)),
+		OtherOpen:         make([]ContextTask, len(otherOpen)),
 		RecentlyCompleted: make([]ContextTask, len(completed)),
+		OpenQuestions:     make([]ContextNote, len(questions)),
 		Summary:           summary,
+		WIPLimits:         wip,
+	}
+
+	for i, q := range questions {
+		output.OpenQuestions[i] = ContextNote{
+			TaskID:    q.TaskID,
+			TaskTitle: q.TaskTitle,
+			Author:    q.Note.Author,
+			Text:      q.Note.Text,
+		}
 	}
 
 	for i, t := range next {
@@ -164,18 +278,32 @@ func outputContextJSON(next, active, completed []task.IndexEntry, summary Summar
 		}
 	}
 
+	for i, t := range otherOpen {
+		output.OtherOpen[i] = ContextTask{
+			ID:     t.ID,
+			Title:  t.Title,
+			Status: t.Status,
+		}
+	}
+
 	for i, t := range completed {
 		output.RecentlyCompleted[i] = ContextTask{
 			ID:        t.ID,
 			Title:     t.Title,
-			Completed: t.Updated.Format("2006-01-02T15:04:05Z07:00"),
+			Status:    t.Status,
+			Completed: completedAt(t).Format("2006-01-02T15:04:05Z07:00"),
 		}
 	}
 
-	data, err := json.MarshalIndent(output, "", "  ")
-	if err != nil {
-		return err
+	return output
+}
+
+// completedAt returns when a closed task was closed. Tasks closed before
+// completion times were recorded, or listed in an index written before
+// they were indexed, fall back to their last update.
+func completedAt(entry task.IndexEntry) time.Time {
+	if entry.Completed != nil {
+		return *entry.Completed
 	}
-	fmt.Println(string(data))
-	return nil
+	return entry.Updated
 }
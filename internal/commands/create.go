@@ -2,54 +2,73 @@ package commands
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/onuse/tasks/internal/store"
 	"github.com/onuse/tasks/internal/task"
 )
 
+// CreateResult is the JSON result of the create command
+type CreateResult struct {
+	ID   int        `json:"id"`
+	Task *task.Task `json:"task"`
+}
+
 func Create(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("usage: task create <title> [description]")
+	fs := newFlagSet("create")
+	if err := parseFlags(fs, args); err != nil {
+		return err
 	}
 
-	title := args[0]
-	if title == "" {
-		return fmt.Errorf("title cannot be empty")
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return invalidArgf("usage: task create <title> [description]")
 	}
 
 	description := ""
-	if len(args) > 1 {
-		description = args[1]
+	if fs.NArg() > 1 {
+		description = fs.Arg(1)
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
 	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	newTask, err := createTask(s, fs.Arg(0), description)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
 	}
 
-	s := store.New(rootDir)
+	if jsonOutput {
+		return writeResult("create", CreateResult{ID: newTask.ID, Task: newTask})
+	}
+
+	fmt.Printf("Created task #%d\n", newTask.ID)
+	return nil
+}
+
+// createTask creates a new backlog task. It is shared by the CLI and the web API.
+func createTask(s *store.Store, title string, description string) (*task.Task, error) {
+	if title == "" {
+		return nil, invalidArgf("title cannot be empty")
+	}
 
 	// Read and update manifest
 	manifest, err := s.ReadManifest()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	taskID := manifest.NextID
 	manifest.NextID++
 
 	if err := s.WriteManifest(manifest); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Create task
 	now := time.Now()
-	newTask := task.Task{
+	newTask := &task.Task{
 		ID:           taskID,
 		Created:      now,
 		Updated:      now,
@@ -62,15 +81,20 @@ func Create(args []string) error {
 		Tags:         []string{},
 	}
 
-	if err := s.WriteTask(&newTask); err != nil {
-		return err
+	if err := s.WriteTask(newTask); err != nil {
+		return nil, err
 	}
 
 	// Rebuild index
 	if err := s.RebuildIndex(); err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Printf("Created task #%d\n", taskID)
-	return nil
+	if err := recordActivity(s, task.SessionEvent{TaskID: taskID, Action: task.ActionCreated, To: title}); err != nil {
+		return nil, err
+	}
+
+	notifyWebhooks(s, EventTaskCreated, nil, newTask, nil)
+
+	return newTask, nil
 }
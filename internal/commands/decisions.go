@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// KindNote is a note of a specific kind together with the task it belongs to
+type KindNote struct {
+	TaskID     int         `json:"task_id"`
+	TaskTitle  string      `json:"task_title"`
+	TaskStatus task.Status `json:"task_status"`
+	Note       task.Note   `json:"note"`
+}
+
+// DecisionsResult is the JSON result of the decisions command
+type DecisionsResult struct {
+	Decisions []KindNote `json:"decisions"`
+	Count     int        `json:"count"`
+}
+
+func Decisions(args []string) error {
+	fs := newFlagSet("decisions")
+	formatFlag := fs.String("format", "text", "Output format (text, json)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	decisions, err := collectNotes(s, task.NoteKindDecision, false)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("decisions", DecisionsResult{Decisions: decisions, Count: len(decisions)})
+	}
+
+	switch *formatFlag {
+	case "json":
+		data, err := json.MarshalIndent(decisions, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	default:
+		return outputKindNotesText(decisions, "No decisions recorded")
+	}
+}
+
+// collectNotes returns every note of the given kind across all tasks, ordered
+// by task ID and then note order. With openOnly, notes on tasks in a closed
+// status (such as done and cancelled) are skipped.
+func collectNotes(s *store.Store, kind string, openOnly bool) ([]KindNote, error) {
+	index, err := s.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []KindNote{}
+	for _, entry := range index.Tasks {
+		if openOnly && task.IsClosedStatus(entry.Status) {
+			continue
+		}
+
+		t, err := s.ReadTask(entry.ID)
+		if err != nil {
+			continue // Skip tasks we can't read
+		}
+
+		for _, note := range t.NotesOfKind(kind) {
+			result = append(result, KindNote{
+				TaskID:     t.ID,
+				TaskTitle:  t.Title,
+				TaskStatus: t.Status,
+				Note:       note,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+func outputKindNotesText(notes []KindNote, empty string) error {
+	if len(notes) == 0 {
+		fmt.Println(empty)
+		return nil
+	}
+
+	lastID := 0
+	for _, n := range notes {
+		if n.TaskID != lastID {
+			if lastID != 0 {
+				fmt.Println()
+			}
+			fmt.Printf("#%-4d [%-9s] %s\n", n.TaskID, n.TaskStatus, n.TaskTitle)
+			lastID = n.TaskID
+		}
+		fmt.Printf("      [%s] %s: %s\n", n.Note.Timestamp.Format("2006-01-02 15:04"), n.Note.Author, n.Note.Text)
+	}
+	return nil
+}
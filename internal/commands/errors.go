@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/onuse/tasks/internal/store"
+)
+
+// Stable error codes reported in JSON mode. Scripts match on these, so
+// existing values must never change meaning.
+const (
+	ErrCodeInvalidArgument = "invalid_argument"
+	ErrCodeUnknownCommand  = "unknown_command"
+	ErrCodeNotFound        = "not_found"
+	ErrCodeNotInitialized  = "not_initialized"
+	ErrCodeAlreadyExists   = "already_exists"
+	ErrCodeConflict        = "conflict"
+	ErrCodeWIPLimit        = "wip_limit"
+	ErrCodeNoChanges       = "no_changes"
+	ErrCodeIO              = "io_error"
+	ErrCodeCorruptData     = "corrupt_data"
+	ErrCodeInternal        = "internal"
+
+	// Web API only
+	ErrCodeUnauthorized         = "unauthorized"
+	ErrCodeForbidden            = "forbidden"
+	ErrCodePreconditionRequired = "precondition_required"
+	ErrCodeUnsupportedMediaType = "unsupported_media_type"
+)
+
+// Process exit codes (see docs/CLI.md)
+const (
+	ExitOK             = 0
+	ExitError          = 1
+	ExitFileSystem     = 2
+	ExitNotInitialized = 3
+)
+
+// CommandError is an error with a stable code, used for both the text and
+// JSON error output.
+type CommandError struct {
+	Code     string
+	Message  string
+	Hint     string
+	ExitCode int
+	Err      error
+	Data     interface{} // Details of the failure for the JSON output, if any
+}
+
+func (e *CommandError) Error() string {
+	return e.Message
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// newError creates a CommandError with the given code
+func newError(code string, format string, args ...interface{}) *CommandError {
+	return &CommandError{
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		ExitCode: ExitError,
+	}
+}
+
+// invalidArgf reports bad user input (usage errors, malformed IDs, unknown values)
+func invalidArgf(format string, args ...interface{}) *CommandError {
+	return newError(ErrCodeInvalidArgument, format, args...)
+}
+
+// notFoundf reports a missing task, label or link
+func notFoundf(format string, args ...interface{}) *CommandError {
+	return newError(ErrCodeNotFound, format, args...)
+}
+
+// classifyError maps any error returned by a command to a CommandError
+func classifyError(err error) *CommandError {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr
+	}
+
+	result := &CommandError{
+		Code:     ErrCodeInternal,
+		Message:  err.Error(),
+		ExitCode: ExitError,
+		Err:      err,
+	}
+
+	var pathErr *fs.PathError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, store.ErrNotInitialized):
+		result.Code = ErrCodeNotInitialized
+		result.Hint = "Run 'task init' to initialize task tracking in this repository."
+		result.ExitCode = ExitNotInitialized
+	case errors.Is(err, store.ErrNotFound):
+		result.Code = ErrCodeNotFound
+	case errors.Is(err, store.ErrAlreadyInitialized), errors.Is(err, store.ErrSessionExists):
+		result.Code = ErrCodeAlreadyExists
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		result.Code = ErrCodeCorruptData
+		result.ExitCode = ExitFileSystem
+	case errors.As(err, &pathErr):
+		result.Code = ErrCodeIO
+		result.ExitCode = ExitFileSystem
+	}
+
+	return result
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// taskETag derives a task's entity tag from its ID and last update time. The
+// timestamp uses the same format as the JSON "updated" field, so clients can
+// build the tag from an index entry without fetching the task.
+func taskETag(id int, updated time.Time) string {
+	return fmt.Sprintf(`"%d-%s"`, id, updated.Format(time.RFC3339Nano))
+}
+
+// indexETag derives the task list's entity tag from the index update time and
+// the query, since filtered lists are different representations
+func indexETag(index *task.Index, rawQuery string) string {
+	tag := "index-" + index.Updated.Format(time.RFC3339Nano)
+	if rawQuery != "" {
+		h := fnv.New32a()
+		h.Write([]byte(rawQuery))
+		tag += fmt.Sprintf("-%08x", h.Sum32())
+	}
+	return `"` + tag + `"`
+}
+
+// checkNotModified sets the validator headers and, when the client's cached
+// copy is still current, writes 304 Not Modified and returns true
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache") // Always revalidate
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagListMatches(inm, etag, true) {
+			return false
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// checkIfMatch enforces optimistic concurrency on writes to an existing task:
+// the request must carry an If-Match header naming the task's current ETag
+// (or *). Callers must hold writeMu so the task can't change before the write.
+func checkIfMatch(r *http.Request, s *store.Store, id int) error {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return newError(ErrCodePreconditionRequired, "If-Match header required; send the task's ETag")
+	}
+
+	t, err := s.ReadTask(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return notFoundf("task #%d not found", id)
+	} else if err != nil {
+		return err
+	}
+
+	if !etagListMatches(ifMatch, taskETag(t.ID, t.Updated), false) {
+		return newError(ErrCodeConflict, "task #%d was modified since it was read; reload and try again", id)
+	}
+	return nil
+}
+
+// setTaskETag reports a task's ETag after a write so clients can chain edits
+func setTaskETag(w http.ResponseWriter, s *store.Store, id int) {
+	if t, err := s.ReadTask(id); err == nil {
+		w.Header().Set("ETag", taskETag(t.ID, t.Updated))
+	}
+}
+
+// etagListMatches checks a comma-separated If-Match / If-None-Match value.
+// Weak tags only match when weak comparison is allowed (If-None-Match).
+func etagListMatches(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+const (
+	// watchInterval is how often the tasks directory is checked for changes
+	watchInterval = 500 * time.Millisecond
+
+	// heartbeatInterval keeps idle event streams from being closed by proxies
+	heartbeatInterval = 15 * time.Second
+
+	// Server-sent event types
+	eventTaskChanged = "task-changed"
+	eventTaskDeleted = "task-deleted"
+)
+
+// taskEvent is pushed to event stream subscribers when a task file changes
+type taskEvent struct {
+	Type string           `json:"type"`
+	ID   int              `json:"id"`
+	Task *task.IndexEntry `json:"task,omitempty"` // Not set for deletions
+}
+
+// eventHub fans task events out to all connected event streams
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan taskEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan taskEvent]struct{})}
+}
+
+func (h *eventHub) subscribe() chan taskEvent {
+	ch := make(chan taskEvent, 64)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan taskEvent) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// publish sends an event to every subscriber. Slow subscribers drop events
+// rather than blocking the watcher; the UI resyncs when it reconnects.
+func (h *eventHub) publish(event taskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// watchTasks polls the tasks directory and publishes an event for every task
+// file created, modified or removed, whether by the CLI, another agent or the
+// web API. It returns when ctx is cancelled.
+func watchTasks(ctx context.Context, s *store.Store, hub *eventHub) {
+	known, err := s.TaskStamps()
+	if err != nil {
+		known = map[int]store.TaskStamp{}
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := s.TaskStamps()
+		if err != nil {
+			continue
+		}
+
+		for id, stamp := range current {
+			if old, ok := known[id]; ok && old == stamp {
+				continue
+			}
+
+			t, err := s.ReadTask(id)
+			if err != nil {
+				continue // Partially written; pick it up on the next tick
+			}
+
+			entry := task.IndexEntry{
+				ID:        t.ID,
+				Status:    t.Status,
+				Title:     t.Title,
+				Created:   t.Created,
+				Updated:   t.Updated,
+				Completed: t.Completed,
+			}
+			hub.publish(taskEvent{Type: eventTaskChanged, ID: id, Task: &entry})
+			known[id] = stamp
+		}
+
+		for id := range known {
+			if _, ok := current[id]; !ok {
+				hub.publish(taskEvent{Type: eventTaskDeleted, ID: id})
+				delete(known, id)
+			}
+		}
+	}
+}
+
+// serveEvents streams task events to the browser as server-sent events
+func serveEvents(w http.ResponseWriter, r *http.Request, hub *eventHub) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// Event streams are long-lived; exempt them from the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	events := hub.subscribe()
+	defer hub.unsubscribe(events)
+
+	// Tell the client how long to wait before reconnecting, and that the
+	// stream is live so it can stop polling and resync
+	fmt.Fprint(w, "retry: 3000\n\n")
+	fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
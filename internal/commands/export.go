@@ -0,0 +1,578 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/markdown"
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// exportFormats are the formats accepted by export --format
+var exportFormats = []string{"csv", "md", "jsonl", "html"}
+
+// ExportResult is the JSON result of the export command. Content is only set
+// when no --out file was given.
+type ExportResult struct {
+	Format  string `json:"format"`
+	Count   int    `json:"count"`
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ExportedTask is a task as exported: labels are resolved to names and kept
+// apart from the other links, which carry their target's title
+type ExportedTask struct {
+	ID          int            `json:"id"`
+	Status      task.Status    `json:"status"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Created     time.Time      `json:"created"`
+	Updated     time.Time      `json:"updated"`
+	Due         string         `json:"due,omitempty"`
+	Completed   *time.Time     `json:"completed,omitempty"`
+	Labels      []string       `json:"labels"`
+	Links       []ExportedLink `json:"links"`
+	Notes       []task.Note    `json:"notes,omitempty"` // Only with --notes
+}
+
+// ExportedLink is a link to another task
+type ExportedLink struct {
+	Type        string `json:"type"`
+	TargetID    int    `json:"target_id"`
+	TargetTitle string `json:"target_title,omitempty"`
+	Label       string `json:"label,omitempty"`
+}
+
+// whereFlags collects repeated --where flags
+type whereFlags []string
+
+func (w *whereFlags) String() string {
+	return strings.Join(*w, " ")
+}
+
+func (w *whereFlags) Set(value string) error {
+	*w = append(*w, value)
+	return nil
+}
+
+func Export(args []string) error {
+	fs := newFlagSet("export")
+	formatFlag := fs.String("format", "md", "Output format (csv, md, jsonl, html)")
+	outFlag := fs.String("out", "", "Write to this file instead of stdout")
+	notesFlag := fs.Bool("notes", false, "Include notes")
+	var where whereFlags
+	fs.Var(&where, "where", "Filter such as status=active,next or due<2025-12-01 (repeatable; all must match)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if !slices.Contains(exportFormats, *formatFlag) {
+		return invalidArgf("invalid format '%s' (must be: %s)", *formatFlag, strings.Join(exportFormats, ", "))
+	}
+
+	// Open the store first: status filters are checked against its workflow
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	var clauses []whereClause
+	for _, expr := range where {
+		clause, err := parseWhere(expr)
+		if err != nil {
+			return err
+		}
+		clauses = append(clauses, clause)
+	}
+
+	tasks, err := exportTasks(s, clauses, *notesFlag)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *formatFlag {
+	case "csv":
+		err = writeExportCSV(&buf, tasks, *notesFlag)
+	case "jsonl":
+		err = writeExportJSONL(&buf, tasks)
+	case "html":
+		err = writeExportHTML(&buf, tasks, projectName(s))
+	default:
+		writeExportMarkdown(&buf, tasks)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *outFlag != "" {
+		if err := os.WriteFile(*outFlag, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+	}
+
+	if jsonOutput {
+		result := ExportResult{Format: *formatFlag, Count: len(tasks), Path: *outFlag}
+		if *outFlag == "" {
+			result.Content = buf.String()
+		}
+		return writeResult("export", result)
+	}
+
+	if *outFlag != "" {
+		fmt.Printf("Exported %d task(s) to %s\n", len(tasks), *outFlag)
+		return nil
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// exportTasks reads the tasks matching all clauses, in ID order. Labels are
+// left out unless a clause selects them by status.
+func exportTasks(s *store.Store, clauses []whereClause, notes bool) ([]ExportedTask, error) {
+	index, err := s.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	titles := make(map[int]string, len(index.Tasks))
+	labels := map[int]string{}
+	for _, entry := range index.Tasks {
+		titles[entry.ID] = entry.Title
+		if entry.Status == task.StatusLabel {
+			labels[entry.ID] = entry.Title
+		}
+	}
+
+	includeLabels := slices.ContainsFunc(clauses, func(c whereClause) bool {
+		return c.field == "status" && c.op == "=" && slices.Contains(c.values, string(task.StatusLabel))
+	})
+
+	entries := slices.Clone(index.Tasks)
+	sortTasks(entries, "id", false)
+
+	tasks := []ExportedTask{}
+	for _, entry := range entries {
+		if entry.Status == task.StatusLabel && !includeLabels {
+			continue
+		}
+
+		t, err := s.ReadTask(entry.ID)
+		if err != nil {
+			continue // Skip tasks we can't read
+		}
+
+		exported := ExportedTask{
+			ID:          t.ID,
+			Status:      t.Status,
+			Title:       t.Title,
+			Description: t.Description,
+			Created:     t.Created,
+			Updated:     t.Updated,
+			Due:         t.Due,
+			Completed:   t.Completed,
+			Labels:      []string{},
+			Links:       []ExportedLink{},
+		}
+		for _, link := range t.Links {
+			if name, ok := labels[link.TargetID]; ok && link.Type == task.LinkTypeChild {
+				exported.Labels = append(exported.Labels, name)
+				continue
+			}
+			title, ok := titles[link.TargetID]
+			if !ok {
+				// Archived tasks aren't in the index
+				if target, err := s.ReadTask(link.TargetID); err == nil {
+					title = target.Title
+				}
+			}
+			exported.Links = append(exported.Links, ExportedLink{
+				Type:        link.Type,
+				TargetID:    link.TargetID,
+				TargetTitle: title,
+				Label:       link.Label,
+			})
+		}
+
+		if !matchesWhere(t, exported.Labels, clauses) {
+			continue
+		}
+		if notes {
+			exported.Notes = t.Notes
+		}
+		tasks = append(tasks, exported)
+	}
+
+	return tasks, nil
+}
+
+// whereClause is a parsed --where filter: field, operator and the values
+// (a comma-separated list, matching any of them)
+type whereClause struct {
+	field  string
+	op     string
+	values []string
+}
+
+var wherePattern = regexp.MustCompile(`^\s*([a-z_]+)\s*(!=|<=|>=|=|<|>)\s*(.*?)\s*$`)
+
+// whereFields lists the fields --where accepts; date fields also allow <, <=,
+// > and >= against a YYYY-MM-DD date
+var whereFields = []string{"status", "tag", "kind", "text", "due", "created", "updated", "completed"}
+
+func parseWhere(expr string) (whereClause, error) {
+	m := wherePattern.FindStringSubmatch(expr)
+	if m == nil {
+		return whereClause{}, invalidArgf("invalid --where '%s' (expected FIELD=VALUE, FIELD!=VALUE or a date comparison)", expr)
+	}
+
+	c := whereClause{field: m[1], op: m[2]}
+	if !slices.Contains(whereFields, c.field) {
+		return c, invalidArgf("invalid --where field '%s' (must be: %s)", c.field, strings.Join(whereFields, ", "))
+	}
+	for _, v := range strings.Split(m[3], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			c.values = append(c.values, v)
+		}
+	}
+
+	if len(c.values) == 0 {
+		return c, invalidArgf("invalid --where '%s' (missing value)", expr)
+	}
+
+	isDate := c.field == "due" || c.field == "created" || c.field == "updated" || c.field == "completed"
+	if c.op != "=" && c.op != "!=" {
+		if !isDate {
+			return c, invalidArgf("operator '%s' only works with dates (due, created, updated, completed)", c.op)
+		}
+		if len(c.values) != 1 || c.values[0] == "none" {
+			return c, invalidArgf("'%s' needs a single date", expr)
+		}
+	}
+
+	for _, v := range c.values {
+		switch {
+		case c.field == "status" && !task.IsValidStatus(v):
+			return c, invalidArgf("invalid status '%s' in --where (must be: %s)", v, statusNames())
+		case c.field == "kind" && !task.IsValidNoteKind(v):
+			return c, invalidArgf("invalid note kind '%s' in --where (must be: %s)", v, strings.Join(task.ValidNoteKinds(), ", "))
+		case isDate && v != "none" && !task.IsValidDueDate(v):
+			return c, invalidArgf("invalid date '%s' in --where (must be YYYY-MM-DD or none)", v)
+		}
+	}
+	return c, nil
+}
+
+// matchesWhere reports whether a task matches every clause
+func matchesWhere(t *task.Task, labels []string, clauses []whereClause) bool {
+	for _, c := range clauses {
+		if !c.matches(t, labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c whereClause) matches(t *task.Task, labels []string) bool {
+	var field string
+	switch c.field {
+	case "status":
+		field = string(t.Status)
+	case "due":
+		field = t.Due
+	case "created":
+		field = t.Created.Format(task.DueDateFormat)
+	case "updated":
+		field = t.Updated.Format(task.DueDateFormat)
+	case "completed":
+		if t.Completed != nil {
+			field = t.Completed.Format(task.DueDateFormat)
+		}
+	}
+
+	// Dates compare as YYYY-MM-DD strings; "none" matches an unset date
+	if c.op != "=" && c.op != "!=" {
+		if field == "" {
+			return false
+		}
+		v := c.values[0]
+		switch c.op {
+		case "<":
+			return field < v
+		case "<=":
+			return field <= v
+		case ">":
+			return field > v
+		default:
+			return field >= v
+		}
+	}
+
+	found := slices.ContainsFunc(c.values, func(v string) bool {
+		switch c.field {
+		case "tag":
+			return slices.ContainsFunc(labels, func(l string) bool { return strings.EqualFold(l, v) })
+		case "kind":
+			return len(t.NotesOfKind(v)) > 0
+		case "text":
+			return matchesQuery(t, strings.ToLower(v))
+		default:
+			if v == "none" {
+				return field == ""
+			}
+			return field == v
+		}
+	})
+	return found == (c.op == "=")
+}
+
+// writeExportCSV writes one row per task. Labels and links are joined with
+// "; " and notes with newlines, so every task stays a single record.
+func writeExportCSV(w io.Writer, tasks []ExportedTask, notes bool) error {
+	cw := csv.NewWriter(w)
+	header := []string{"id", "status", "title", "description", "created", "updated", "due", "completed", "labels", "links"}
+	if notes {
+		header = append(header, "notes")
+	}
+	cw.Write(header)
+
+	for _, t := range tasks {
+		completed := ""
+		if t.Completed != nil {
+			completed = t.Completed.Format(time.RFC3339)
+		}
+		links := make([]string, len(t.Links))
+		for i, link := range t.Links {
+			links[i] = formatExportLink(link, false)
+		}
+
+		row := []string{
+			fmt.Sprint(t.ID), string(t.Status), t.Title, t.Description,
+			t.Created.Format(time.RFC3339), t.Updated.Format(time.RFC3339), t.Due, completed,
+			strings.Join(t.Labels, "; "), strings.Join(links, "; "),
+		}
+		if notes {
+			lines := make([]string, len(t.Notes))
+			for i, note := range t.Notes {
+				lines[i] = fmt.Sprintf("[%s] %s: %s", note.Timestamp.Format("2006-01-02 15:04"), noteByline(note), note.Text)
+			}
+			row = append(row, strings.Join(lines, "\n"))
+		}
+		cw.Write(row)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeExportJSONL writes one JSON object per line
+func writeExportJSONL(w io.Writer, tasks []ExportedTask) error {
+	enc := json.NewEncoder(w)
+	for _, t := range tasks {
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeExportMarkdown writes a roadmap grouped by status. It contains no
+// export timestamp, so a committed ROADMAP.md only changes with the tasks.
+func writeExportMarkdown(w io.Writer, tasks []ExportedTask) {
+	fmt.Fprintln(w, "# Roadmap")
+
+	for _, status := range task.ValidStatuses() {
+		var group []ExportedTask
+		for _, t := range tasks {
+			if t.Status == status {
+				group = append(group, t)
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n## %s\n", statusHeading(status))
+		for _, t := range group {
+			fmt.Fprintf(w, "\n### #%d %s\n", t.ID, t.Title)
+
+			var meta []string
+			if len(t.Labels) > 0 {
+				meta = append(meta, "Labels: `"+strings.Join(t.Labels, "`, `")+"`")
+			}
+			if t.Due != "" {
+				meta = append(meta, "Due: "+t.Due)
+			}
+			if t.Completed != nil {
+				meta = append(meta, "Completed: "+t.Completed.Format(task.DueDateFormat))
+			}
+			if len(meta) > 0 {
+				fmt.Fprintf(w, "\n%s\n", strings.Join(meta, " · "))
+			}
+
+			if t.Description != "" {
+				fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(t.Description))
+			}
+
+			if len(t.Links) > 0 {
+				fmt.Fprintln(w)
+				for _, link := range t.Links {
+					fmt.Fprintf(w, "- %s\n", formatExportLink(link, true))
+				}
+			}
+
+			if len(t.Notes) > 0 {
+				fmt.Fprintf(w, "\nNotes:\n\n")
+				for _, note := range t.Notes {
+					text := strings.ReplaceAll(strings.TrimSpace(note.Text), "\n", "\n  ")
+					fmt.Fprintf(w, "- %s, %s: %s\n", note.Timestamp.Format("2006-01-02"), noteByline(note), text)
+				}
+			}
+		}
+	}
+}
+
+// formatExportLink describes a link, e.g. "blocks #12 (Set up CI)"
+func formatExportLink(link ExportedLink, withTitle bool) string {
+	s := fmt.Sprintf("%s #%d", link.Type, link.TargetID)
+	if withTitle && link.TargetTitle != "" {
+		s += " " + link.TargetTitle
+	}
+	if link.Label != "" {
+		s += " (" + link.Label + ")"
+	}
+	return s
+}
+
+// noteByline is a note's author, with its kind if it has one
+func noteByline(note task.Note) string {
+	if note.Kind != "" {
+		return fmt.Sprintf("%s (%s)", note.Author, note.Kind)
+	}
+	return note.Author
+}
+
+// statusHeading capitalizes a status for use as a heading
+func statusHeading(status task.Status) string {
+	s := string(status)
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// taskRefPattern matches the task references rendered by markdown.ToHTML,
+// which the report turns into links to the task's section
+var taskRefPattern = regexp.MustCompile(`<a href="#" class="task-ref" data-task="(\d+)">`)
+
+func reportMarkdown(src string) template.HTML {
+	rendered := markdown.ToHTML(src)
+	rendered = taskRefPattern.ReplaceAllString(rendered, `<a href="#task-$1" class="task-ref">`)
+	return template.HTML(rendered) // ToHTML escapes and sanitizes its input
+}
+
+// reportData is passed to reportTemplate
+type reportData struct {
+	Project   string
+	Generated time.Time
+	Total     int
+	Groups    []reportGroup
+}
+
+type reportGroup struct {
+	Status task.Status
+	Tasks  []ExportedTask
+}
+
+// writeExportHTML writes a self-contained HTML report: styles are inline and
+// nothing is loaded from elsewhere
+func writeExportHTML(w io.Writer, tasks []ExportedTask, project string) error {
+	data := reportData{Project: project, Generated: time.Now(), Total: len(tasks)}
+	for _, status := range task.ValidStatuses() {
+		group := reportGroup{Status: status}
+		for _, t := range tasks {
+			if t.Status == status {
+				group.Tasks = append(group.Tasks, t)
+			}
+		}
+		if len(group.Tasks) > 0 {
+			data.Groups = append(data.Groups, group)
+		}
+	}
+
+	return reportTemplate.Execute(w, data)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"markdown": reportMarkdown,
+	"heading":  statusHeading,
+	"byline":   noteByline,
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Tasks: {{.Project}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 960px; margin: 2rem auto; padding: 0 1rem; color: #222; line-height: 1.5; }
+header { border-bottom: 2px solid #eee; margin-bottom: 1.5rem; }
+.summary { color: #666; }
+.summary a { margin-right: 1rem; }
+h2 { margin-top: 2.5rem; border-bottom: 1px solid #eee; padding-bottom: .3rem; }
+.task { border: 1px solid #ddd; border-radius: 6px; padding: .75rem 1rem; margin: 1rem 0; }
+.task h3 { margin: 0 0 .25rem; font-size: 1.1rem; }
+.task h3 .id { color: #888; font-weight: normal; }
+.meta { font-size: .9rem; color: #666; }
+.status { display: inline-block; padding: 0 .5rem; border-radius: 10px; background: #eee; font-size: .8rem; text-transform: uppercase; }
+.status-active { background: #d4edda; } .status-blocked { background: #f8d7da; } .status-next { background: #fff3cd; }
+.status-done { background: #d1ecf1; } .status-cancelled { background: #e2e3e5; }
+.label { display: inline-block; padding: 0 .4rem; border-radius: 3px; background: #e7f0ff; color: #1a4fa0; font-size: .8rem; }
+.links, .notes { font-size: .9rem; }
+.notes li { margin-bottom: .4rem; }
+.note-meta { color: #888; }
+pre { background: #f6f8fa; padding: .5rem; overflow-x: auto; }
+code { background: #f6f8fa; padding: 0 .2rem; }
+</style>
+</head>
+<body>
+<header>
+<h1>Tasks: {{.Project}}</h1>
+<p class="summary">{{.Total}} task(s), generated {{date .Generated}}<br>
+{{range .Groups}}<a href="#{{.Status}}">{{heading .Status}}: {{len .Tasks}}</a>{{end}}</p>
+</header>
+{{range .Groups}}
+<h2 id="{{.Status}}">{{heading .Status}}</h2>
+{{range .Tasks}}
+<section class="task" id="task-{{.ID}}">
+<h3><span class="id">#{{.ID}}</span> {{.Title}}</h3>
+<div class="meta"><span class="status status-{{.Status}}">{{.Status}}</span>
+{{range .Labels}} <span class="label">{{.}}</span>{{end}}
+{{if .Due}} · Due {{.Due}}{{end}}{{if .Completed}} · Completed {{date .Completed}}{{end}}
+· Updated {{date .Updated}}</div>
+{{if .Description}}<div class="description">{{markdown .Description}}</div>{{end}}
+{{if .Links}}<ul class="links">{{range .Links}}
+<li>{{.Type}} <a href="#task-{{.TargetID}}">#{{.TargetID}}</a> {{.TargetTitle}}{{if .Label}} ({{.Label}}){{end}}</li>{{end}}
+</ul>{{end}}
+{{if .Notes}}<ul class="notes">{{range .Notes}}
+<li><span class="note-meta">{{date .Timestamp}} · {{byline .}}</span>{{markdown .Text}}</li>{{end}}
+</ul>{{end}}
+</section>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
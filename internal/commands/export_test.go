@@ -0,0 +1,20 @@
+package commands
+
+import "testing"
+
+func TestReportMarkdown(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"Blocked by #12", `<p>Blocked by <a href="#task-12" class="task-ref">#12</a></p>` + "\n"},
+		{"<script>#3</script>", `<p>&lt;script&gt;<a href="#task-3" class="task-ref">#3</a>&lt;/script&gt;</p>` + "\n"},
+		{"[x](javascript:alert(1))", "<p>x</p>\n"},
+	}
+
+	for _, tt := range tests {
+		if got := string(reportMarkdown(tt.src)); got != tt.want {
+			t.Errorf("reportMarkdown(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
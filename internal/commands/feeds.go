@@ -0,0 +1,456 @@
+package commands
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/markdown"
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// defaultFeedLimit is the number of /feed.atom entries without ?limit=
+const defaultFeedLimit = 50
+
+// feedPaths are served to clients that can only be given a URL. Feed readers
+// and calendar apps can't send headers or keep cookies, so these accept the
+// bearer token as ?token= on every request.
+var feedPaths = map[string]bool{
+	"/feed.atom":    true,
+	"/calendar.ics": true,
+}
+
+// Activity kinds shown in the Atom feed
+const (
+	activityCreated   = "created"
+	activityStatus    = "status"
+	activityCompleted = "completed"
+	activityNote      = "note"
+)
+
+// activity is one change to a task, as shown in the Atom feed
+type activity struct {
+	Key     string // Unique, stable key the entry ID is derived from
+	Time    time.Time
+	Kind    string
+	Task    *task.Task
+	Author  string
+	Summary string // Entry title after the task reference
+	Text    string // Markdown body
+}
+
+// feedTasks reads the tasks matching the status, tag and q parameters. Label
+// tasks are left out unless asked for with status=label.
+func feedTasks(s *store.Store, r *http.Request) ([]*task.Task, *task.Index, int, error) {
+	q, err := parseTaskQuery(r.URL.Query())
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	limit := q.Limit
+	q.Limit, q.Offset = 0, 0
+
+	index, err := s.ReadIndex()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	page, err := queryTasks(s, index, q)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	var tasks []*task.Task
+	for _, entry := range page.Tasks {
+		if entry.Status == task.StatusLabel && q.Status != string(task.StatusLabel) {
+			continue
+		}
+		t, err := s.ReadTask(entry.ID)
+		if err != nil {
+			continue // Skip tasks we can't read
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, index, limit, nil
+}
+
+// collectActivity lists creations, notes and status changes of the given
+// tasks, newest first. Status changes come from session journals. A task's
+// current status set outside any session is taken from the task itself, as
+// are completions recorded before tasks kept their status time.
+func collectActivity(s *store.Store, tasks []*task.Task) ([]activity, error) {
+	byID := make(map[int]*task.Task, len(tasks))
+	var result []activity
+
+	for _, t := range tasks {
+		byID[t.ID] = t
+		result = append(result, activity{
+			Key:     fmt.Sprintf("task/%d/created", t.ID),
+			Time:    t.Created,
+			Kind:    activityCreated,
+			Task:    t,
+			Summary: "created",
+			Text:    t.Description,
+		})
+
+		for _, note := range t.Notes {
+			summary := "note"
+			if note.Kind != "" {
+				summary = note.Kind
+			}
+			result = append(result, activity{
+				Key:     fmt.Sprintf("task/%d/note/%s", t.ID, note.Timestamp.UTC().Format(time.RFC3339Nano)),
+				Time:    note.Timestamp,
+				Kind:    activityNote,
+				Task:    t,
+				Author:  note.Author,
+				Summary: summary + " by " + note.Author,
+				Text:    note.Text,
+			})
+		}
+	}
+
+	sessions, err := s.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks whose current status change is in a journal
+	journaled := map[int]bool{}
+	for _, session := range sessions {
+		for _, event := range session.Events {
+			t, ok := byID[event.TaskID]
+			if !ok || event.Action != task.ActionStatus {
+				continue
+			}
+			if event.To == string(t.Status) {
+				since := t.StatusSince
+				if since == nil {
+					since = t.Completed
+				}
+				if since != nil && event.Timestamp.Sub(*since).Abs() < time.Minute {
+					journaled[t.ID] = true
+				}
+			}
+			result = append(result, activity{
+				Key:     fmt.Sprintf("session/%s/task/%d/status/%s", session.ID, t.ID, event.Timestamp.UTC().Format(time.RFC3339Nano)),
+				Time:    event.Timestamp,
+				Kind:    activityStatus,
+				Task:    t,
+				Author:  session.Agent,
+				Summary: fmt.Sprintf("%s → %s", event.From, event.To),
+			})
+		}
+	}
+
+	for _, t := range tasks {
+		switch {
+		case journaled[t.ID]:
+		case t.Completed != nil:
+			result = append(result, activity{
+				Key:     fmt.Sprintf("task/%d/completed/%s", t.ID, t.Completed.UTC().Format(time.RFC3339Nano)),
+				Time:    *t.Completed,
+				Kind:    activityCompleted,
+				Task:    t,
+				Author:  t.StatusBy,
+				Summary: "completed",
+			})
+		case t.StatusSince != nil:
+			result = append(result, activity{
+				Key:     fmt.Sprintf("task/%d/status/%s", t.ID, t.StatusSince.UTC().Format(time.RFC3339Nano)),
+				Time:    *t.StatusSince,
+				Kind:    activityStatus,
+				Task:    t,
+				Author:  t.StatusBy,
+				Summary: "→ " + string(t.Status),
+			})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result, nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title    string       `xml:"title"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Link     atomLink     `xml:"link"`
+	Author   *atomPerson  `xml:"author,omitempty"`
+	Category atomCategory `xml:"category"`
+	Content  *atomContent `xml:"content,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// serveAtomFeed serves recent task activity as an Atom feed
+func serveAtomFeed(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	tasks, index, limit, err := feedTasks(s, r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if checkNotModified(w, r, indexETag(index, feedQuery(r)), index.Updated) {
+		return
+	}
+
+	activities, err := collectActivity(s, tasks)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if limit == 0 {
+		limit = defaultFeedLimit
+	}
+	activities = activities[:min(limit, len(activities))]
+
+	base := baseURL(r)
+	name := projectName(s)
+	self := base + r.URL.Path
+	if query := feedQuery(r); query != "" {
+		self += "?" + query
+	}
+	feed := atomFeed{
+		Title:   "Tasks: " + name,
+		ID:      feedID("feed/" + name),
+		Updated: index.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+			{Rel: "alternate", Type: "text/html", Href: base + "/"},
+		},
+		Author: atomPerson{Name: "task"},
+	}
+	if len(activities) > 0 && activities[0].Time.After(index.Updated) {
+		feed.Updated = activities[0].Time.UTC().Format(time.RFC3339)
+	}
+
+	for _, a := range activities {
+		entry := atomEntry{
+			Title:    fmt.Sprintf("#%d %s: %s", a.Task.ID, a.Task.Title, a.Summary),
+			ID:       feedID(a.Key),
+			Updated:  a.Time.UTC().Format(time.RFC3339),
+			Link:     atomLink{Rel: "alternate", Type: "text/html", Href: fmt.Sprintf("%s/#task-%d", base, a.Task.ID)},
+			Category: atomCategory{Term: a.Kind},
+		}
+		if a.Author != "" {
+			entry.Author = &atomPerson{Name: a.Author}
+		}
+		if a.Text != "" {
+			entry.Content = &atomContent{Type: "html", Body: markdown.ToHTML(a.Text)}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed) // Too late to report errors once the body has started
+}
+
+// serveCalendar serves tasks with a due or completion date as iCalendar: a
+// VTODO per task, plus all-day VEVENTs on due dates and timed VEVENTs at
+// completion for calendar apps that don't show to-dos
+func serveCalendar(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	tasks, index, _, err := feedTasks(s, r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if checkNotModified(w, r, indexETag(index, feedQuery(r)), index.Updated) {
+		return
+	}
+
+	base := baseURL(r)
+	name := projectName(s)
+
+	// Labels become categories
+	labels := map[int]string{}
+	for _, entry := range index.Tasks {
+		if entry.Status == task.StatusLabel {
+			labels[entry.ID] = entry.Title
+		}
+	}
+
+	cal := &icalWriter{}
+	cal.line("BEGIN", "VCALENDAR")
+	cal.line("VERSION", "2.0")
+	cal.line("PRODID", "-//onuse//task//EN")
+	cal.line("CALSCALE", "GREGORIAN")
+	cal.line("X-WR-CALNAME", icalText("Tasks: "+name))
+
+	for _, t := range tasks {
+		if t.Due == "" && t.Completed == nil {
+			continue
+		}
+		uid := fmt.Sprintf("task-%d@%s", t.ID, icalText(name))
+		summary := icalText(fmt.Sprintf("#%d %s", t.ID, t.Title))
+		stamp := t.Updated.UTC().Format(icalTimeFormat)
+		link := fmt.Sprintf("%s/#task-%d", base, t.ID)
+		due := strings.ReplaceAll(t.Due, "-", "")
+
+		cal.line("BEGIN", "VTODO")
+		cal.line("UID", uid)
+		cal.line("DTSTAMP", stamp)
+		cal.line("CREATED", t.Created.UTC().Format(icalTimeFormat))
+		cal.line("LAST-MODIFIED", stamp)
+		cal.line("SUMMARY", summary)
+		if t.Description != "" {
+			cal.line("DESCRIPTION", icalText(t.Description))
+		}
+		cal.line("URL", link)
+		cal.line("STATUS", icalTodoStatus(t.Status))
+		if due != "" {
+			cal.line("DUE;VALUE=DATE", due)
+		}
+		if t.Completed != nil {
+			cal.line("COMPLETED", t.Completed.UTC().Format(icalTimeFormat))
+		}
+		var categories []string
+		for _, link := range t.Links {
+			if name, ok := labels[link.TargetID]; ok && link.Type == task.LinkTypeChild {
+				categories = append(categories, icalText(name))
+			}
+		}
+		if len(categories) > 0 {
+			cal.line("CATEGORIES", strings.Join(categories, ","))
+		}
+		cal.line("END", "VTODO")
+
+		if due != "" {
+			dueDate, _ := time.Parse(task.DueDateFormat, t.Due)
+			cal.line("BEGIN", "VEVENT")
+			cal.line("UID", "due-"+uid)
+			cal.line("DTSTAMP", stamp)
+			cal.line("DTSTART;VALUE=DATE", due)
+			cal.line("DTEND;VALUE=DATE", dueDate.AddDate(0, 0, 1).Format("20060102"))
+			cal.line("SUMMARY", "Due: "+summary)
+			cal.line("URL", link)
+			cal.line("TRANSP", "TRANSPARENT")
+			cal.line("END", "VEVENT")
+		}
+
+		if t.Completed != nil {
+			cal.line("BEGIN", "VEVENT")
+			cal.line("UID", "completed-"+uid)
+			cal.line("DTSTAMP", stamp)
+			cal.line("DTSTART", t.Completed.UTC().Format(icalTimeFormat))
+			cal.line("SUMMARY", "Done: "+summary)
+			cal.line("URL", link)
+			cal.line("TRANSP", "TRANSPARENT")
+			cal.line("END", "VEVENT")
+		}
+	}
+
+	cal.line("END", "VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(cal.String()))
+}
+
+// icalTimeFormat is an iCalendar UTC date-time
+const icalTimeFormat = "20060102T150405Z"
+
+// icalWriter builds an iCalendar document with CRLF line endings and lines
+// folded at 75 octets (RFC 5545 section 3.1)
+type icalWriter struct {
+	strings.Builder
+}
+
+func (c *icalWriter) line(name string, value string) {
+	line := name + ":" + value
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut-- // Don't split a UTF-8 sequence
+		}
+		c.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	c.WriteString(line + "\r\n")
+}
+
+// icalText escapes a TEXT property value
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icalTodoStatus maps a task status to a VTODO STATUS value. Every closed
+// status other than cancelled is COMPLETED, matching the COMPLETED date.
+func icalTodoStatus(status task.Status) string {
+	switch {
+	case status == task.StatusCancelled:
+		return "CANCELLED"
+	case task.IsClosedStatus(status):
+		return "COMPLETED"
+	case status == task.StatusActive, status == task.StatusBlocked:
+		return "IN-PROCESS"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+// feedQuery is the request's query without the token, so tokens don't end
+// up in ETags or in the feed's self link
+func feedQuery(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("token")
+	return query.Encode()
+}
+
+// baseURL is the scheme and host the request was made to
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// projectName names the repository in feed and calendar titles
+func projectName(s *store.Store) string {
+	return filepath.Base(s.Root())
+}
+
+// feedID turns a key into a stable urn:uuid (a name-based, version 5 style
+// UUID), as Atom entry IDs must never change
+func feedID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+func TestIcalTodoStatus(t *testing.T) {
+	workflow := task.DefaultWorkflow()
+	workflow.Statuses = append(workflow.Statuses,
+		task.WorkflowStatus{Name: "review", Category: task.CategoryOpen},
+		task.WorkflowStatus{Name: "shipped", Category: task.CategoryClosed},
+	)
+	task.SetWorkflow(workflow)
+	t.Cleanup(func() { task.SetWorkflow(nil) })
+
+	tests := []struct {
+		status task.Status
+		want   string
+	}{
+		{task.StatusBacklog, "NEEDS-ACTION"},
+		{"review", "NEEDS-ACTION"},
+		{task.StatusActive, "IN-PROCESS"},
+		{task.StatusBlocked, "IN-PROCESS"},
+		{task.StatusDone, "COMPLETED"},
+		{"shipped", "COMPLETED"},
+		{task.StatusCancelled, "CANCELLED"},
+	}
+
+	for _, tt := range tests {
+		if got := icalTodoStatus(tt.status); got != tt.want {
+			t.Errorf("icalTodoStatus(%s) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// Task references in commit messages: "#12" or "task-12", and closing
+// references such as "fixes #12" or "closes task-12"
+var (
+	commitRefPattern   = regexp.MustCompile(`(?i)(?:^|[^\w/&])(?:#|task-)(\d+)\b`)
+	commitClosePattern = regexp.MustCompile(`(?i)\b(?:fix(?:es|ed)?|close[sd]?|resolve[sd]?):?\s+(?:#|task-)(\d+)\b`)
+)
+
+// hookMarker identifies hooks written by "task hooks install", so they can be
+// replaced and removed without touching other hooks
+const hookMarker = "# Installed by \"task hooks install\""
+
+// GitCommit is a commit that references a task
+type GitCommit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+	Closes  bool      `json:"closes"` // The message closes the task ("fixes #12")
+}
+
+// GitLogResult is the JSON result of the git-log command
+type GitLogResult struct {
+	ID      int         `json:"id"`
+	Commits []GitCommit `json:"commits"`
+	Count   int         `json:"count"`
+}
+
+// HooksResult is the JSON result of hooks install and uninstall
+type HooksResult struct {
+	Hooks []GitHook `json:"hooks"`
+}
+
+// GitHook is a hook installed or removed by the hooks command
+type GitHook struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func GitLog(args []string) error {
+	if len(args) < 1 {
+		return invalidArgf("usage: task git-log <id> [--all] [--limit N]")
+	}
+	id, err := parseTaskID(args[0], "task ID")
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("git-log")
+	allFlag := fs.Bool("all", false, "Search all branches, not just the current one")
+	limitFlag := fs.Int("limit", 0, "Show at most N commits (0 for all)")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if *limitFlag < 0 {
+		return invalidArgf("--limit cannot be negative")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	if _, err := s.ReadTask(id); errors.Is(err, store.ErrNotFound) {
+		return notFoundf("task #%d not found", id)
+	} else if err != nil {
+		return err
+	}
+
+	commits, err := commitsReferencing(s, id, *allFlag)
+	if err != nil {
+		return err
+	}
+	if *limitFlag > 0 && len(commits) > *limitFlag {
+		commits = commits[:*limitFlag]
+	}
+
+	if jsonOutput {
+		return writeResult("git-log", GitLogResult{ID: id, Commits: commits, Count: len(commits)})
+	}
+
+	if len(commits) == 0 {
+		fmt.Printf("No commits reference task #%d\n", id)
+		return nil
+	}
+	for _, c := range commits {
+		closes := ""
+		if c.Closes {
+			closes = " (closes)"
+		}
+		fmt.Printf("%s %s %s: %s%s\n", c.Hash[:min(len(c.Hash), 7)], c.Date.Format("2006-01-02"), c.Author, c.Subject, closes)
+	}
+	return nil
+}
+
+// commitsReferencing returns the commits whose message references a task,
+// newest first. git's --grep narrows the log down; the exact match is done
+// here so "#12" doesn't match "#123".
+func commitsReferencing(s *store.Store, id int, all bool) ([]GitCommit, error) {
+	args := []string{"log", "-E", "-i", "--grep=(#|task-)" + strconv.Itoa(id), "--format=%H%x1f%an%x1f%aI%x1f%s%x1f%B%x1e"}
+	if all {
+		args = append(args, "--all")
+	}
+	out, err := runGit(s.Root(), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []GitCommit{}
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		if !slices.Contains(taskReferences(fields[4]), id) {
+			continue
+		}
+
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, GitCommit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    date,
+			Subject: fields[3],
+			Closes:  slices.Contains(closingReferences(fields[4]), id),
+		})
+	}
+	return commits, nil
+}
+
+// taskReferences returns the task IDs a commit message references, in order
+// and without duplicates
+func taskReferences(message string) []int {
+	return matchedIDs(commitRefPattern, message)
+}
+
+// closingReferences returns the task IDs a commit message closes
+func closingReferences(message string) []int {
+	return matchedIDs(commitClosePattern, message)
+}
+
+func matchedIDs(pattern *regexp.Regexp, message string) []int {
+	var ids []int
+	for _, m := range pattern.FindAllStringSubmatch(message, -1) {
+		id, err := strconv.Atoi(m[1])
+		if err == nil && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// runGit runs git in dir and returns its output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("git not found in PATH")
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func Hooks(args []string) error {
+	if len(args) < 1 {
+		return invalidArgf("usage: task hooks <install|uninstall|run> [options]")
+	}
+
+	switch args[0] {
+	case "install":
+		return hooksInstall(args[1:])
+	case "uninstall":
+		return hooksUninstall(args[1:])
+	case "run":
+		return hooksRun(args[1:])
+	default:
+		return invalidArgf("unknown hooks subcommand '%s' (must be: install, uninstall, run)", args[0])
+	}
+}
+
+func hooksInstall(args []string) error {
+	fs := newFlagSet("hooks install")
+	requireFlag := fs.Bool("require", false, "Reject commits that don't reference a task")
+	autoFlag := fs.Bool("auto-transition", false, "Move tasks to done when a commit says \"fixes #ID\"")
+	forceFlag := fs.Bool("force", false, "Replace existing hooks not installed by task")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	dir, err := hooksDir(s)
+	if err != nil {
+		return err
+	}
+
+	commitMsg := "task hooks run commit-msg"
+	if *requireFlag {
+		commitMsg += " --require"
+	}
+	scripts := map[string]string{"commit-msg": commitMsg + ` "$1"`}
+	names := []string{"commit-msg"}
+	if *autoFlag {
+		scripts["post-commit"] = "task hooks run post-commit"
+		names = append(names, "post-commit")
+	}
+
+	// Check every hook before writing any, so a conflict changes nothing
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if ours, exists := ownHook(path); exists && !ours && !*forceFlag {
+			return newError(ErrCodeConflict, "%s already exists and was not installed by task (use --force to replace it)", path)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	result := HooksResult{Hooks: []GitHook{}}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		script := "#!/bin/sh\n" +
+			hookMarker + "; remove with \"task hooks uninstall\".\n" +
+			"command -v task >/dev/null 2>&1 || exit 0\n" +
+			"exec " + scripts[name] + "\n"
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			return err
+		}
+		result.Hooks = append(result.Hooks, GitHook{Name: name, Path: path})
+	}
+
+	// Reinstalling without --auto-transition turns it off
+	if !*autoFlag {
+		if removed, err := removeOwnHook(dir, "post-commit"); err != nil {
+			return err
+		} else if removed {
+			fmt.Fprintf(os.Stderr, "Removed post-commit hook (auto-transition is off)\n")
+		}
+	}
+
+	if jsonOutput {
+		return writeResult("hooks", result)
+	}
+
+	for _, hook := range result.Hooks {
+		fmt.Printf("Installed %s hook (%s)\n", hook.Name, hook.Path)
+	}
+	return nil
+}
+
+func hooksUninstall(args []string) error {
+	fs := newFlagSet("hooks uninstall")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	dir, err := hooksDir(s)
+	if err != nil {
+		return err
+	}
+
+	result := HooksResult{Hooks: []GitHook{}}
+	for _, name := range []string{"commit-msg", "post-commit"} {
+		removed, err := removeOwnHook(dir, name)
+		if err != nil {
+			return err
+		}
+		if removed {
+			result.Hooks = append(result.Hooks, GitHook{Name: name, Path: filepath.Join(dir, name)})
+		}
+	}
+
+	if jsonOutput {
+		return writeResult("hooks", result)
+	}
+
+	if len(result.Hooks) == 0 {
+		fmt.Println("No task hooks installed")
+		return nil
+	}
+	for _, hook := range result.Hooks {
+		fmt.Printf("Removed %s hook (%s)\n", hook.Name, hook.Path)
+	}
+	return nil
+}
+
+// hooksDir returns the repository's hooks directory, honoring core.hooksPath
+func hooksDir(s *store.Store) (string, error) {
+	out, err := runGit(s.Root(), "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(out)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.Root(), dir)
+	}
+	return dir, nil
+}
+
+// ownHook reports whether a hook was installed by task, and whether it exists
+func ownHook(path string) (ours, exists bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, false
+	}
+	return bytes.Contains(data, []byte(hookMarker)), true
+}
+
+// removeOwnHook removes a hook if it was installed by task
+func removeOwnHook(dir, name string) (bool, error) {
+	path := filepath.Join(dir, name)
+	if ours, _ := ownHook(path); !ours {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// hooksRun is called by the installed hooks
+func hooksRun(args []string) error {
+	if len(args) < 1 {
+		return invalidArgf("usage: task hooks run <commit-msg|post-commit> [options]")
+	}
+
+	switch args[0] {
+	case "commit-msg":
+		return runCommitMsgHook(args[1:])
+	case "post-commit":
+		return runPostCommitHook(args[1:])
+	default:
+		return invalidArgf("unknown hook '%s' (must be: commit-msg, post-commit)", args[0])
+	}
+}
+
+// runCommitMsgHook checks the task references in a commit message. A
+// reference to a task that doesn't exist gets a warning, or with --require
+// rejects the commit: "#12" may well mean an issue or pull request. A message
+// without references gets one to the active task if there is exactly one;
+// otherwise the commit is rejected with --require.
+func runCommitMsgHook(args []string) error {
+	fs := newFlagSet("hooks run commit-msg")
+	requireFlag := fs.Bool("require", false, "Reject commits that don't reference a task")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return invalidArgf("usage: task hooks run commit-msg [--require] <message-file>")
+	}
+	path := fs.Arg(0)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+
+	// Git drops comment lines and everything below the scissors line, so
+	// only the rest is the message
+	var message []string
+	last := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "# ------------------------ >8 ------------------------") {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		message = append(message, line)
+		if strings.TrimSpace(line) != "" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil // Empty message; git aborts the commit itself
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	if refs := taskReferences(strings.Join(message, "\n")); len(refs) > 0 {
+		for _, id := range refs {
+			if _, err := s.ReadTask(id); err != nil {
+				if !errors.Is(err, store.ErrNotFound) {
+					return err
+				}
+				if *requireFlag {
+					return notFoundf("commit message references task #%d, which does not exist", id)
+				}
+				fmt.Fprintf(os.Stderr, "Warning: commit message references task #%d, which does not exist\n", id)
+			}
+		}
+		return nil
+	}
+
+	index, err := s.ReadIndex()
+	if err != nil {
+		return err
+	}
+	var active []int
+	for _, entry := range index.Tasks {
+		if entry.Status == task.StatusActive {
+			active = append(active, entry.ID)
+		}
+	}
+
+	if len(active) != 1 {
+		if !*requireFlag {
+			return nil
+		}
+		msg := "commit message must reference a task (#ID or task-ID)"
+		if len(active) > 1 {
+			ids := make([]string, len(active))
+			for i, id := range active {
+				ids[i] = "#" + strconv.Itoa(id)
+			}
+			msg += "; active tasks: " + strings.Join(ids, ", ")
+		}
+		return invalidArgf("%s", msg)
+	}
+
+	// Add the reference as a trailer after the last line of the message
+	ref := fmt.Sprintf("Refs #%d", active[0])
+	lines = slices.Insert(lines, last+1, "", ref)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Added \"%s\" to the commit message\n", ref)
+	return nil
+}
+
+// runPostCommitHook moves the tasks the last commit closes to done
+func runPostCommitHook(args []string) error {
+	fs := newFlagSet("hooks run post-commit")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	out, err := runGit(s.Root(), "log", "-1", "--format=%H%x1f%s%x1f%B")
+	if err != nil {
+		return err
+	}
+	fields := strings.SplitN(out, "\x1f", 3)
+	if len(fields) != 3 {
+		return nil
+	}
+	hash, subject, body := fields[0], fields[1], fields[2]
+
+	for _, id := range closingReferences(body) {
+		t, err := s.ReadTask(id)
+		if err != nil || task.IsClosedStatus(t.Status) {
+			continue
+		}
+
+		status := string(task.StatusDone)
+		note := fmt.Sprintf("Closed by commit %s: %s", hash[:min(len(hash), 7)], subject)
+		if _, _, err := updateTask(s, id, TaskChanges{Status: &status, Note: &note, System: true}); err != nil {
+			return err
+		}
+		fmt.Printf("Moved task #%d to done\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// maxGraphDepth caps the neighborhood depth of GET /api/graph
+const maxGraphDepth = 10
+
+// GraphResult is the task dependency graph returned by GET /api/graph
+type GraphResult struct {
+	Focus int         `json:"focus,omitempty"`
+	Depth int         `json:"depth,omitempty"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a task in the graph
+type GraphNode struct {
+	ID     int         `json:"id"`
+	Title  string      `json:"title"`
+	Status task.Status `json:"status"`
+}
+
+// GraphEdge is a link between two tasks. Reciprocal links (blocks and
+// blocked_by, parent and child) are reported once, as "blocks" or "parent"
+// pointing from the blocking or parent task.
+type GraphEdge struct {
+	Source int    `json:"source"`
+	Target int    `json:"target"`
+	Type   string `json:"type"`
+	Label  string `json:"label,omitempty"`
+}
+
+// serveGraphAPI handles GET /api/graph?focus=&depth=&labels=
+func serveGraphAPI(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	index, err := s.ReadIndex()
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	focus := 0
+	if v := query.Get("focus"); v != "" {
+		if focus, err = strconv.Atoi(v); err != nil {
+			writeAPIError(w, invalidArgf("invalid task ID '%s'", v))
+			return
+		}
+	}
+
+	depth := 1
+	if v := query.Get("depth"); v != "" {
+		depth, err = strconv.Atoi(v)
+		if err != nil || depth < 1 || depth > maxGraphDepth {
+			writeAPIError(w, invalidArgf("invalid depth '%s' (must be 1-%d)", v, maxGraphDepth))
+			return
+		}
+	}
+
+	if checkNotModified(w, r, indexETag(index, r.URL.RawQuery), index.Updated) {
+		return
+	}
+
+	graph, err := buildGraph(s, index, focus, depth, query.Get("labels") == "true")
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	writeAPIJSON(w, http.StatusOK, graph)
+}
+
+// buildGraph collects tasks and their links. With a focus task, only tasks
+// within depth links of it (in either direction) are included; otherwise
+// every task with at least one link is. Label tasks, and the tag links
+// pointing at them, are left out unless includeLabels is set.
+func buildGraph(s *store.Store, index *task.Index, focus int, depth int, includeLabels bool) (*GraphResult, error) {
+	nodes := make(map[int]GraphNode)
+	var tasks []*task.Task
+	for _, entry := range index.Tasks {
+		if entry.Status == task.StatusLabel && !includeLabels && entry.ID != focus {
+			continue
+		}
+
+		t, err := s.ReadTask(entry.ID)
+		if err != nil {
+			continue // Skip tasks we can't read
+		}
+		tasks = append(tasks, t)
+		nodes[t.ID] = GraphNode{ID: t.ID, Title: t.Title, Status: t.Status}
+	}
+
+	if focus != 0 {
+		if _, ok := nodes[focus]; !ok {
+			return nil, notFoundf("task #%d not found", focus)
+		}
+	}
+
+	// Collect each relationship once, whichever side recorded it
+	seen := make(map[GraphEdge]int) // Position in edges
+	var edges []GraphEdge
+	adjacent := make(map[int][]int)
+	for _, t := range tasks {
+		for _, link := range t.Links {
+			if _, ok := nodes[link.TargetID]; !ok {
+				continue
+			}
+
+			edge := canonicalEdge(t.ID, link)
+			key := GraphEdge{Source: edge.Source, Target: edge.Target, Type: edge.Type}
+			if i, ok := seen[key]; ok {
+				if edges[i].Label == "" {
+					edges[i].Label = edge.Label
+				}
+				continue
+			}
+			seen[key] = len(edges)
+
+			edges = append(edges, edge)
+			adjacent[edge.Source] = append(adjacent[edge.Source], edge.Target)
+			adjacent[edge.Target] = append(adjacent[edge.Target], edge.Source)
+		}
+	}
+
+	// Decide which tasks to show
+	include := make(map[int]bool)
+	if focus != 0 {
+		include[focus] = true
+		frontier := []int{focus}
+		for level := 0; level < depth && len(frontier) > 0; level++ {
+			var next []int
+			for _, id := range frontier {
+				for _, neighbor := range adjacent[id] {
+					if !include[neighbor] {
+						include[neighbor] = true
+						next = append(next, neighbor)
+					}
+				}
+			}
+			frontier = next
+		}
+	} else {
+		for id := range adjacent {
+			include[id] = true
+		}
+	}
+
+	result := &GraphResult{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	if focus != 0 {
+		result.Focus = focus
+		result.Depth = depth
+	}
+
+	for id := range include {
+		result.Nodes = append(result.Nodes, nodes[id])
+	}
+	sort.Slice(result.Nodes, func(i, j int) bool {
+		return result.Nodes[i].ID < result.Nodes[j].ID
+	})
+
+	for _, edge := range edges {
+		if include[edge.Source] && include[edge.Target] {
+			result.Edges = append(result.Edges, edge)
+		}
+	}
+
+	return result, nil
+}
+
+// canonicalEdge turns a link recorded on a task into a direction-independent
+// edge: blocked_by and child become blocks and parent with the ends swapped,
+// and symmetric links point from the lower ID.
+func canonicalEdge(sourceID int, link task.TaskLink) GraphEdge {
+	edge := GraphEdge{Source: sourceID, Target: link.TargetID, Type: link.Type, Label: link.Label}
+
+	switch link.Type {
+	case task.LinkTypeBlocks, task.LinkTypeParent:
+	case task.LinkTypeBlockedBy, task.LinkTypeChild:
+		edge.Source, edge.Target = edge.Target, edge.Source
+		edge.Type = getReciprocalLinkType(link.Type)
+	default:
+		if edge.Source > edge.Target {
+			edge.Source, edge.Target = edge.Target, edge.Source
+		}
+	}
+
+	return edge
+}
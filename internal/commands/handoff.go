@@ -0,0 +1,267 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// HandoffOutput is the brief handed to the next agent
+type HandoffOutput struct {
+	Agent         string        `json:"agent"`
+	Session       *SessionBrief `json:"session,omitempty"`
+	WorkedOn      []HandoffTask `json:"worked_on"`
+	InProgress    []HandoffTask `json:"in_progress"`
+	Blocked       []HandoffTask `json:"blocked"`
+	SuggestedNext *HandoffTask  `json:"suggested_next,omitempty"`
+}
+
+// SessionBrief summarizes the session a handoff was built from
+type SessionBrief struct {
+	ID      string     `json:"id"`
+	Started time.Time  `json:"started"`
+	Ended   *time.Time `json:"ended,omitempty"`
+	Summary string     `json:"summary,omitempty"`
+}
+
+// HandoffTask is a task as it appears in a handoff brief
+type HandoffTask struct {
+	ID         int         `json:"id"`
+	Title      string      `json:"title"`
+	Status     task.Status `json:"status"`
+	LatestNote *task.Note  `json:"latest_note,omitempty"`
+	BlockedBy  []int       `json:"blocked_by,omitempty"` // Unfinished tasks this one waits on
+	Reason     string      `json:"reason,omitempty"`     // Why the task was suggested
+}
+
+func Handoff(args []string) error {
+	fs := newFlagSet("handoff")
+	formatFlag := fs.String("format", "markdown", "Output format (markdown, json)")
+	agentFlag := fs.String("agent", currentAgent(), "Agent whose work is handed off (defaults to the global --agent or $TASK_AGENT)")
+	sessionFlag := fs.String("session", "", "Session to hand off (default: the agent's current or latest session)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	output, err := buildHandoff(s, *agentFlag, *sessionFlag)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("handoff", output)
+	}
+
+	switch *formatFlag {
+	case "json":
+		data, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "markdown":
+		fmt.Print(renderHandoffMarkdown(output))
+		return nil
+	default:
+		return invalidArgf("invalid format '%s' (must be: markdown, json)", *formatFlag)
+	}
+}
+
+// findHandoffSession returns the session to hand off: the given one, the
+// agent's open session, or the agent's most recent session. It returns nil
+// when the agent has no sessions.
+func findHandoffSession(s *store.Store, agent string, sessionID string) (*task.Session, error) {
+	if sessionID != "" {
+		return s.ReadSession(sessionID)
+	}
+
+	sessions, err := s.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(sessions) - 1; i >= 0; i-- {
+		if sessions[i].Agent == agent {
+			return &sessions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func buildHandoff(s *store.Store, agent string, sessionID string) (*HandoffOutput, error) {
+	session, err := findHandoffSession(s, agent, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := s.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[int]task.Status, len(index.Tasks))
+	for _, entry := range index.Tasks {
+		statuses[entry.ID] = entry.Status
+	}
+
+	output := &HandoffOutput{
+		Agent:      agent,
+		WorkedOn:   []HandoffTask{},
+		InProgress: []HandoffTask{},
+		Blocked:    []HandoffTask{},
+	}
+
+	if session != nil {
+		// A session given with --session may belong to another agent
+		output.Agent = session.Agent
+		output.Session = &SessionBrief{
+			ID:      session.ID,
+			Started: session.Started,
+			Ended:   session.Ended,
+			Summary: session.Summary,
+		}
+
+		for _, id := range session.Tasks {
+			t, err := s.ReadTask(id)
+			if err != nil {
+				continue // Task may have been removed since
+			}
+			output.WorkedOn = append(output.WorkedOn, newHandoffTask(t, statuses))
+		}
+	}
+
+	var suggestion *HandoffTask
+	for _, entry := range index.Tasks {
+		switch entry.Status {
+		case task.StatusActive, task.StatusBlocked, task.StatusNext, task.StatusBacklog:
+		default:
+			continue
+		}
+
+		t, err := s.ReadTask(entry.ID)
+		if err != nil {
+			continue
+		}
+		ht := newHandoffTask(t, statuses)
+
+		switch entry.Status {
+		case task.StatusActive:
+			output.InProgress = append(output.InProgress, ht)
+		case task.StatusBlocked:
+			output.Blocked = append(output.Blocked, ht)
+		case task.StatusNext:
+			// Prefer the lowest-ID unblocked next task
+			if len(ht.BlockedBy) == 0 && (suggestion == nil || suggestion.Status != task.StatusNext) {
+				ht.Reason = "first unblocked task in next"
+				suggestion = &ht
+			}
+		case task.StatusBacklog:
+			if len(ht.BlockedBy) == 0 && suggestion == nil {
+				ht.Reason = "no unblocked next tasks; oldest unblocked backlog task"
+				suggestion = &ht
+			}
+		}
+	}
+	output.SuggestedNext = suggestion
+
+	return output, nil
+}
+
+// newHandoffTask builds a HandoffTask, resolving unfinished blockers using the
+// statuses from the index
+func newHandoffTask(t *task.Task, statuses map[int]task.Status) HandoffTask {
+	ht := HandoffTask{
+		ID:     t.ID,
+		Title:  t.Title,
+		Status: t.Status,
+	}
+
+	if len(t.Notes) > 0 {
+		note := t.Notes[len(t.Notes)-1]
+		ht.LatestNote = &note
+	}
+
+	for _, link := range t.GetLinks(task.LinkTypeBlockedBy) {
+		status, ok := statuses[link.TargetID]
+		if ok && !task.IsClosedStatus(status) {
+			ht.BlockedBy = append(ht.BlockedBy, link.TargetID)
+		}
+	}
+
+	return ht
+}
+
+func renderHandoffMarkdown(h *HandoffOutput) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Handoff from %s\n\n", h.Agent)
+
+	if h.Session != nil {
+		ended := "still open"
+		if h.Session.Ended != nil {
+			ended = "ended " + h.Session.Ended.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(&b, "Session `%s` (started %s, %s)\n\n", h.Session.ID, h.Session.Started.Format("2006-01-02 15:04"), ended)
+		if h.Session.Summary != "" {
+			fmt.Fprintf(&b, "> %s\n\n", h.Session.Summary)
+		}
+	} else {
+		b.WriteString("No session recorded for this agent.\n\n")
+	}
+
+	writeSection := func(title string, tasks []HandoffTask) {
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		if len(tasks) == 0 {
+			b.WriteString("None\n\n")
+			return
+		}
+		for _, t := range tasks {
+			b.WriteString(formatHandoffTask(t))
+		}
+		b.WriteString("\n")
+	}
+
+	writeSection("Worked On", h.WorkedOn)
+	writeSection("In Progress", h.InProgress)
+	writeSection("Blocked", h.Blocked)
+
+	b.WriteString("## Suggested Next\n\n")
+	if h.SuggestedNext != nil {
+		b.WriteString(formatHandoffTask(*h.SuggestedNext))
+		fmt.Fprintf(&b, "  - Why: %s\n", h.SuggestedNext.Reason)
+	} else {
+		b.WriteString("Nothing ready to pick up\n")
+	}
+
+	return b.String()
+}
+
+// formatHandoffTask renders a task as a markdown list item
+func formatHandoffTask(t HandoffTask) string {
+	line := fmt.Sprintf("- #%d %s [%s]\n", t.ID, t.Title, t.Status)
+
+	if len(t.BlockedBy) > 0 {
+		ids := make([]string, len(t.BlockedBy))
+		for i, id := range t.BlockedBy {
+			ids[i] = fmt.Sprintf("#%d", id)
+		}
+		line += fmt.Sprintf("  - Blocked by: %s\n", strings.Join(ids, ", "))
+	}
+
+	if t.LatestNote != nil {
+		line += fmt.Sprintf("  - Latest note (%s, %s): %s\n",
+			t.LatestNote.Author, t.LatestNote.Timestamp.Format("2006-01-02 15:04"), t.LatestNote.Text)
+	}
+
+	return line
+}
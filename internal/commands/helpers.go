@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"flag"
+	"io"
+	"strconv"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// openStore finds the task root and returns a store for it, with the
+// repository's workflow in effect
+func openStore() (*store.Store, error) {
+	rootDir, err := store.FindTaskRoot()
+	if err != nil {
+		return nil, err
+	}
+	s := store.New(rootDir)
+
+	config, err := s.ReadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config.Workflow != nil {
+		if err := config.Workflow.Validate(); err != nil {
+			return nil, newError(ErrCodeCorruptData, "invalid workflow in %s: %v", store.ConfigFile, err)
+		}
+	}
+	task.SetWorkflow(config.Workflow)
+	if err := validateWIPLimits(config.WIPLimits); err != nil {
+		return nil, newError(ErrCodeCorruptData, "invalid wip_limits in %s: %v", store.ConfigFile, err)
+	}
+
+	return s, nil
+}
+
+// newFlagSet creates a flag set that reports parse errors instead of exiting,
+// so they go through the normal error output (text or JSON). It also accepts
+// the global --json flag among the command's options.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if jsonOutput {
+		fs.SetOutput(io.Discard)
+	}
+	fs.BoolFunc("json", "Write the result as JSON (same as the global --json)", func(string) error {
+		SetJSONOutput(true)
+		fs.SetOutput(io.Discard)
+		return nil
+	})
+	return fs
+}
+
+// parseFlags parses args into fs, classifying failures as invalid arguments.
+// Options may come after positional arguments ("task create Title --json"),
+// which are left in fs.Args(); everything after "--" is positional.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return &CommandError{
+				Code:     ErrCodeInvalidArgument,
+				Message:  err.Error(),
+				ExitCode: ExitError,
+				Err:      err,
+			}
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	// Parse the positional arguments on their own so fs.Args() returns them
+	return fs.Parse(append([]string{"--"}, positional...))
+}
+
+// parseTaskID parses a task ID argument; kind is used in the error message
+// (e.g. "source task ID")
+func parseTaskID(arg string, kind string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, invalidArgf("invalid %s '%s'", kind, arg)
+	}
+	return id, nil
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestParseFlagsAfterArguments(t *testing.T) {
+	t.Cleanup(func() { SetJSONOutput(false) })
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantArgs []string
+		wantJSON bool
+		wantTag  string
+	}{
+		{"flags first", []string{"--json", "--tag", "x", "Title"}, []string{"Title"}, true, "x"},
+		{"flags last", []string{"Title", "Description", "--json"}, []string{"Title", "Description"}, true, ""},
+		{"flags between arguments", []string{"Title", "--tag", "x", "Description"}, []string{"Title", "Description"}, false, "x"},
+		{"everything after -- is an argument", []string{"Title", "--", "--json", "-x"}, []string{"Title", "--json", "-x"}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetJSONOutput(false)
+			fs := newFlagSet("test")
+			tag := fs.String("tag", "", "")
+			if err := parseFlags(fs, tt.args); err != nil {
+				t.Fatalf("parseFlags: %v", err)
+			}
+			if !slices.Equal(fs.Args(), tt.wantArgs) || jsonOutput != tt.wantJSON || *tag != tt.wantTag {
+				t.Errorf("args %q json %v tag %q, want %q %v %q", fs.Args(), jsonOutput, *tag, tt.wantArgs, tt.wantJSON, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestParseFlagsRejectsUnknownOptions(t *testing.T) {
+	fs := newFlagSet("test")
+	fs.SetOutput(io.Discard)
+	if err := parseFlags(fs, []string{"Title", "--bogus"}); err == nil {
+		t.Errorf("parseFlags accepted an unknown option")
+	}
+}
@@ -0,0 +1,778 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// Import sources
+const (
+	ImportGitHub   = "github"
+	ImportGitLab   = "gitlab"
+	ImportMarkdown = "markdown"
+)
+
+// ImportResult is the JSON result of the import command
+type ImportResult struct {
+	Source    string         `json:"source"`
+	Created   []ImportedTask `json:"created"`
+	Updated   []ImportedTask `json:"updated"`
+	Unchanged []ImportedTask `json:"unchanged"`
+	Links     int            `json:"links"` // Links added between imported tasks
+}
+
+// ImportedTask maps an imported issue to its task
+type ImportedTask struct {
+	External string `json:"external"`
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+}
+
+// issue is an issue from any source, normalized for import
+type issue struct {
+	Ref        task.ExternalRef
+	Number     int
+	Title      string
+	Body       *string // nil if the source has no descriptions
+	Closed     bool
+	NotPlanned bool // Closed without being done
+	ClosedAt   *time.Time
+	Labels     []string
+	Comments   []task.Note
+	Parent     string // External ID of the parent issue, if any
+	TaskID     int    // Task the source already refers to, if any
+}
+
+// Issue references that become links, e.g. "Blocked by #12"
+var (
+	blockedByPattern = regexp.MustCompile(`(?i)\b(?:blocked by|depends on)\s+#(\d+)`)
+	blocksPattern    = regexp.MustCompile(`(?i)\bblocks\s+#(\d+)`)
+)
+
+func Import(args []string) error {
+	fs := newFlagSet("import")
+	fromFlag := fs.String("from", "", "Format of the file (github, gitlab, markdown)")
+	rewriteFlag := fs.Bool("rewrite", false, "Add task references to the Markdown file")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return invalidArgf("usage: task import --from github|gitlab|markdown <file>")
+	}
+	path := fs.Arg(0)
+
+	switch *fromFlag {
+	case ImportGitHub, ImportGitLab, ImportMarkdown:
+	default:
+		return invalidArgf("invalid --from '%s' (must be: github, gitlab, markdown)", *fromFlag)
+	}
+	if *rewriteFlag && *fromFlag != ImportMarkdown {
+		return invalidArgf("--rewrite only applies to --from markdown")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return notFoundf("file '%s' not found", path)
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	var issues []issue
+	switch *fromFlag {
+	case ImportGitHub:
+		issues, err = parseGitHubIssues(data)
+	case ImportGitLab:
+		issues, err = parseGitLabIssues(data)
+	case ImportMarkdown:
+		var file string
+		if file, err = repoPath(s, path); err != nil {
+			return err
+		}
+		issues = parseMarkdownPlan(data, file)
+	}
+	if err != nil {
+		return newError(ErrCodeInvalidArgument, "failed to parse %s: %v", path, err)
+	}
+
+	result, err := importIssues(s, *fromFlag, issues)
+	if err != nil {
+		return err
+	}
+
+	rewritten := false
+	if *rewriteFlag {
+		if rewritten, err = rewriteMarkdownPlan(path, data, issues, result); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput {
+		return writeResult("import", result)
+	}
+
+	noun := "issue(s)"
+	if *fromFlag == ImportMarkdown {
+		noun = "item(s)"
+	}
+	fmt.Printf("Imported %d %s from %s: %d created, %d updated, %d unchanged, %d link(s) added\n",
+		len(issues), noun, result.Source, len(result.Created), len(result.Updated), len(result.Unchanged), result.Links)
+	for _, t := range result.Created {
+		fmt.Printf("  created  %s -> #%d %s\n", t.External, t.ID, t.Title)
+	}
+	for _, t := range result.Updated {
+		fmt.Printf("  updated  %s -> #%d %s\n", t.External, t.ID, t.Title)
+	}
+	if rewritten {
+		fmt.Printf("Added task references to %s\n", path)
+	}
+	return nil
+}
+
+// importIssues creates or updates a task per issue. Tasks are matched to
+// issues by their external ID, so importing the same export again only
+// applies what changed.
+func importIssues(s *store.Store, source string, issues []issue) (ImportResult, error) {
+	result := ImportResult{Source: source, Created: []ImportedTask{}, Updated: []ImportedTask{}, Unchanged: []ImportedTask{}}
+
+	existing, err := externalTaskIDs(s, source)
+	if err != nil {
+		return result, err
+	}
+
+	taskIDs := make(map[string]int, len(issues))
+	changed := map[string]bool{}
+	var created []string
+
+	for _, is := range issues {
+		id, ok := existing[is.Ref.ID]
+		if is.TaskID != 0 {
+			if _, err := s.ReadTask(is.TaskID); err == nil {
+				id, ok = is.TaskID, true
+			}
+		}
+		if !ok {
+			var description string
+			if is.Body != nil {
+				description = *is.Body
+			}
+			t, err := createTask(s, is.Title, description)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", is.Ref.ID, err)
+			}
+			id = t.ID
+			existing[is.Ref.ID] = id
+			created = append(created, is.Ref.ID)
+		}
+		taskIDs[is.Ref.ID] = id
+
+		updated, err := applyIssue(s, id, is)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", is.Ref.ID, err)
+		}
+		changed[is.Ref.ID] = updated
+
+		for _, name := range is.Labels {
+			tagged, err := tagTask(s, id, name)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", is.Ref.ID, err)
+			}
+			changed[is.Ref.ID] = changed[is.Ref.ID] || tagged.Changed
+		}
+	}
+
+	// Links need every issue imported first, as references point both ways
+	for _, is := range issues {
+		id := taskIDs[is.Ref.ID]
+		for _, ref := range issueReferences(is) {
+			target, ok := taskIDs[ref.external]
+			if !ok {
+				target, ok = existing[ref.external]
+			}
+			if !ok || target == id {
+				continue // Not imported from this source
+			}
+
+			t, err := s.ReadTask(id)
+			if err != nil {
+				return result, err
+			}
+			if t.HasLink(target, ref.linkType) {
+				continue
+			}
+
+			if _, err := linkTasks(s, id, target, ref.linkType, "", true); err != nil {
+				return result, fmt.Errorf("%s: %w", is.Ref.ID, err)
+			}
+			result.Links++
+			changed[is.Ref.ID] = true
+		}
+	}
+
+	isCreated := map[string]bool{}
+	for _, ref := range created {
+		isCreated[ref] = true
+	}
+	for _, is := range issues {
+		imported := ImportedTask{External: is.Ref.ID, ID: taskIDs[is.Ref.ID], Title: is.Title}
+		switch {
+		case isCreated[is.Ref.ID]:
+			result.Created = append(result.Created, imported)
+		case changed[is.Ref.ID]:
+			result.Updated = append(result.Updated, imported)
+		default:
+			result.Unchanged = append(result.Unchanged, imported)
+		}
+	}
+
+	return result, nil
+}
+
+// applyIssue brings a task up to date with its issue: title, description,
+// open/closed state and comments not imported yet. Status is only changed
+// when the issue was opened or closed, so local statuses such as active
+// survive a re-import.
+func applyIssue(s *store.Store, id int, is issue) (bool, error) {
+	t, err := s.ReadTask(id)
+	if err != nil {
+		return false, err
+	}
+	before := t.Clone()
+
+	var events []task.SessionEvent
+	if t.External == nil || (t.External.Source == is.Ref.Source && *t.External != is.Ref) {
+		ref := is.Ref
+		t.External = &ref
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionImport, To: is.Ref.Source + " " + is.Ref.ID})
+	}
+
+	if t.Title != is.Title {
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionTitle, From: t.Title, To: is.Title})
+		t.Title = is.Title
+	}
+	if is.Body != nil && t.Description != *is.Body {
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionDescription})
+		t.Description = *is.Body
+	}
+
+	closed := task.IsClosedStatus(t.Status)
+	status := t.Status
+	switch {
+	case is.Closed && !closed && is.NotPlanned:
+		status = task.StatusCancelled
+	case is.Closed && !closed:
+		status = task.StatusDone
+	case !is.Closed && closed:
+		status = task.StatusBacklog
+	}
+	// Mirroring the issue is a system transition (see checkTransition)
+	if err := checkTransition(t, status, true); err != nil {
+		return false, err
+	}
+	if status != t.Status {
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionStatus, From: string(t.Status), To: string(status)})
+		now := time.Now()
+		t.Status = status
+		t.StatusBy = currentAgent()
+		t.StatusSince = &now
+		t.Completed = nil
+		if task.IsClosedStatus(status) {
+			completed := now
+			if is.ClosedAt != nil {
+				completed = *is.ClosedAt
+			}
+			t.Completed = &completed
+		}
+	}
+
+	for _, comment := range is.Comments {
+		if !hasNote(t, comment) {
+			t.Notes = append(t.Notes, comment)
+			events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionNote, To: comment.Text})
+		}
+	}
+
+	if len(events) == 0 {
+		return false, nil
+	}
+
+	t.Updated = time.Now()
+	if err := s.WriteTask(t); err != nil {
+		return false, err
+	}
+	if err := s.RebuildIndex(); err != nil {
+		return false, err
+	}
+	if err := recordActivity(s, events...); err != nil {
+		return false, err
+	}
+
+	notifyWebhooks(s, EventTaskUpdated, before, t, nil)
+	return true, nil
+}
+
+// hasNote reports whether a task already has an imported comment
+func hasNote(t *task.Task, note task.Note) bool {
+	for _, n := range t.Notes {
+		if n.Timestamp.Equal(note.Timestamp) && n.Author == note.Author && n.Text == note.Text {
+			return true
+		}
+	}
+	return false
+}
+
+// externalTaskIDs maps the external IDs of tasks imported from source to
+// task IDs
+func externalTaskIDs(s *store.Store, source string) (map[string]int, error) {
+	ids := map[string]int{}
+	err := s.ForEachTask(func(t *task.Task) error {
+		if t.External != nil && t.External.Source == source {
+			ids[t.External.ID] = t.ID
+		}
+		return nil
+	})
+	return ids, err
+}
+
+type issueReference struct {
+	external string // External ID of the linked issue
+	linkType string
+}
+
+// issueReferences returns the issues an issue links to: its parent, and
+// "blocked by #n", "depends on #n" and "blocks #n" in its description
+func issueReferences(is issue) []issueReference {
+	var refs []issueReference
+	if is.Parent != "" {
+		refs = append(refs, issueReference{external: is.Parent, linkType: task.LinkTypeChild})
+	}
+	if is.Body == nil {
+		return refs
+	}
+	for _, m := range blockedByPattern.FindAllStringSubmatch(*is.Body, -1) {
+		n, _ := strconv.Atoi(m[1])
+		refs = append(refs, issueReference{external: siblingRef(is.Ref.ID, n), linkType: task.LinkTypeBlockedBy})
+	}
+	for _, m := range blocksPattern.FindAllStringSubmatch(*is.Body, -1) {
+		n, _ := strconv.Atoi(m[1])
+		refs = append(refs, issueReference{external: siblingRef(is.Ref.ID, n), linkType: task.LinkTypeBlocks})
+	}
+	return refs
+}
+
+// siblingRef is the external ID of issue n in the same project as ref
+func siblingRef(ref string, n int) string {
+	project, _, _ := strings.Cut(ref, "#")
+	return project + "#" + strconv.Itoa(n)
+}
+
+// externalID builds "project#n" from an issue URL matched by pattern, or
+// "#n" if there is no usable URL
+func externalID(pattern *regexp.Regexp, url string, n int) string {
+	if m := pattern.FindStringSubmatch(url); m != nil {
+		return m[1] + "#" + strconv.Itoa(n)
+	}
+	return "#" + strconv.Itoa(n)
+}
+
+// unwrapIssues accepts a JSON array of issues, or an object holding one
+// under "issues"
+func unwrapIssues(data []byte) ([]json.RawMessage, error) {
+	var raw []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var wrapper struct {
+			Issues []json.RawMessage `json:"issues"`
+		}
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, err
+		}
+		return wrapper.Issues, nil
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// githubURLPattern extracts "owner/repo" from web and API issue URLs
+var githubURLPattern = regexp.MustCompile(`github\.com/(?:repos/)?([^/]+/[^/]+)/issues/\d+`)
+
+// githubIssue accepts both the REST API format (snake_case, as saved from
+// /repos/{owner}/{repo}/issues) and the format of "gh issue list --json"
+// (camelCase, with comments included)
+type githubIssue struct {
+	Number        int             `json:"number"`
+	Title         string          `json:"title"`
+	Body          string          `json:"body"`
+	State         string          `json:"state"`
+	StateReason   string          `json:"state_reason"`
+	StateReasonGH string          `json:"stateReason"`
+	ClosedAt      *time.Time      `json:"closed_at"`
+	ClosedAtGH    *time.Time      `json:"closedAt"`
+	HTMLURL       string          `json:"html_url"`
+	URL           string          `json:"url"`
+	Labels        []githubLabel   `json:"labels"`
+	Comments      json.RawMessage `json:"comments"` // A count in the REST API, a list from gh
+	PullRequest   json.RawMessage `json:"pull_request"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+type githubComment struct {
+	Body        string     `json:"body"`
+	CreatedAt   *time.Time `json:"created_at"`
+	CreatedAtGH *time.Time `json:"createdAt"`
+	User        githubUser `json:"user"`
+	Author      githubUser `json:"author"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+func parseGitHubIssues(data []byte) ([]issue, error) {
+	raw, err := unwrapIssues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []issue
+	for _, r := range raw {
+		var gh githubIssue
+		if err := json.Unmarshal(r, &gh); err != nil {
+			return nil, err
+		}
+		if len(gh.PullRequest) > 0 && string(gh.PullRequest) != "null" {
+			continue // The REST API lists pull requests as issues
+		}
+		if gh.Number == 0 || gh.Title == "" {
+			return nil, fmt.Errorf("issue without number or title")
+		}
+
+		url := gh.HTMLURL
+		if url == "" {
+			url = gh.URL
+		}
+		reason := strings.ToLower(gh.StateReason + gh.StateReasonGH)
+
+		is := issue{
+			Ref:        task.ExternalRef{Source: ImportGitHub, ID: externalID(githubURLPattern, url, gh.Number), URL: url},
+			Number:     gh.Number,
+			Title:      gh.Title,
+			Body:       &gh.Body,
+			Closed:     strings.EqualFold(gh.State, "closed"),
+			NotPlanned: reason == "not_planned",
+			ClosedAt:   gh.ClosedAt,
+		}
+		if is.ClosedAt == nil {
+			is.ClosedAt = gh.ClosedAtGH
+		}
+		for _, label := range gh.Labels {
+			is.Labels = append(is.Labels, label.Name)
+		}
+
+		var comments []githubComment
+		if json.Unmarshal(gh.Comments, &comments) == nil {
+			for _, c := range comments {
+				note := task.Note{Text: c.Body, Author: c.User.Login}
+				if note.Author == "" {
+					note.Author = c.Author.Login
+				}
+				if c.CreatedAt != nil {
+					note.Timestamp = *c.CreatedAt
+				} else if c.CreatedAtGH != nil {
+					note.Timestamp = *c.CreatedAtGH
+				}
+				is.Comments = append(is.Comments, note)
+			}
+		}
+
+		issues = append(issues, is)
+	}
+
+	sortIssues(issues)
+	return issues, nil
+}
+
+// gitlabURLPattern extracts the project path from issue URLs
+var gitlabURLPattern = regexp.MustCompile(`^https?://[^/]+/(.+?)/-/issues/\d+`)
+
+// gitlabIssue is an issue from the GitLab API (/projects/:id/issues), or
+// from a project export, which also includes notes
+type gitlabIssue struct {
+	IID         int             `json:"iid"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	State       string          `json:"state"`
+	ClosedAt    *time.Time      `json:"closed_at"`
+	WebURL      string          `json:"web_url"`
+	Labels      json.RawMessage `json:"labels"` // Names, or objects with with_labels_details
+	Notes       []gitlabNote    `json:"notes"`
+}
+
+type gitlabNote struct {
+	Body      string    `json:"body"`
+	Note      string    `json:"note"` // Project exports use "note" for the text
+	CreatedAt time.Time `json:"created_at"`
+	System    bool      `json:"system"`
+	Author    struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+func parseGitLabIssues(data []byte) ([]issue, error) {
+	raw, err := unwrapIssues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []issue
+	for _, r := range raw {
+		var gl gitlabIssue
+		if err := json.Unmarshal(r, &gl); err != nil {
+			return nil, err
+		}
+		if gl.IID == 0 || gl.Title == "" {
+			return nil, fmt.Errorf("issue without iid or title")
+		}
+
+		is := issue{
+			Ref:      task.ExternalRef{Source: ImportGitLab, ID: externalID(gitlabURLPattern, gl.WebURL, gl.IID), URL: gl.WebURL},
+			Number:   gl.IID,
+			Title:    gl.Title,
+			Body:     &gl.Description,
+			Closed:   gl.State == "closed",
+			ClosedAt: gl.ClosedAt,
+		}
+
+		var names []string
+		var detailed []githubLabel
+		if json.Unmarshal(gl.Labels, &names) == nil {
+			is.Labels = names
+		} else if json.Unmarshal(gl.Labels, &detailed) == nil {
+			for _, label := range detailed {
+				is.Labels = append(is.Labels, label.Name)
+			}
+		}
+
+		for _, n := range gl.Notes {
+			if n.System {
+				continue // "changed the description", "added label", ...
+			}
+			text := n.Body
+			if text == "" {
+				text = n.Note
+			}
+			is.Comments = append(is.Comments, task.Note{Timestamp: n.CreatedAt, Author: n.Author.Username, Text: text})
+		}
+
+		issues = append(issues, is)
+	}
+
+	sortIssues(issues)
+	return issues, nil
+}
+
+// sortIssues orders issues and their comments oldest first, so tasks are
+// created in issue order
+func sortIssues(issues []issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Number < issues[j].Number
+	})
+	for _, is := range issues {
+		sort.SliceStable(is.Comments, func(i, j int) bool {
+			return is.Comments[i].Timestamp.Before(is.Comments[j].Timestamp)
+		})
+	}
+}
+
+// Markdown plan syntax: headings, checklist items, and the task reference
+// that --rewrite appends to both
+var (
+	planHeadingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	planItemPattern    = regexp.MustCompile(`^([ \t]*)(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+	planRefPattern     = regexp.MustCompile(`\s*\(#(\d+)\)$`)
+	planFencePattern   = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+// planNode is a heading or checklist item in a Markdown plan
+type planNode struct {
+	issue    *issue
+	heading  int // Heading level, 0 for checklist items
+	indent   int // Indentation of checklist items
+	checked  bool
+	children []*planNode
+}
+
+// parseMarkdownPlan turns a Markdown checklist into issues: headings become
+// parents of the items and headings below them, nested items children of
+// the item above. Checked items are closed, and headings are closed when
+// every item below them is. Headings without items are skipped.
+//
+// Each item's external ID is its path in the file, e.g.
+// "PLAN.md#Phase 1/Write tests", so importing the file again finds the same
+// tasks as long as titles don't change. Items carrying a "(#12)" reference
+// are matched to that task instead.
+func parseMarkdownPlan(data []byte, file string) []issue {
+	var roots []*planNode
+	var headings, items []*planNode
+	used := map[string]int{}
+	inFence := false
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if planFencePattern.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		node := &planNode{}
+		var title string
+		if m := planHeadingPattern.FindStringSubmatch(line); m != nil {
+			node.heading = len(m[1])
+			title = m[2]
+		} else if m := planItemPattern.FindStringSubmatch(line); m != nil {
+			node.indent = len(strings.ReplaceAll(m[1], "\t", "    "))
+			node.checked = m[2] != " "
+			title = m[3]
+		} else {
+			continue
+		}
+
+		is := &issue{Number: i + 1, Closed: node.checked}
+		if m := planRefPattern.FindStringSubmatch(title); m != nil {
+			is.TaskID, _ = strconv.Atoi(m[1])
+			title = title[:len(title)-len(m[0])]
+		}
+		is.Title = strings.TrimSpace(title)
+		if is.Title == "" {
+			continue
+		}
+		node.issue = is
+
+		var parent *planNode
+		if node.heading > 0 {
+			for len(headings) > 0 && headings[len(headings)-1].heading >= node.heading {
+				headings = headings[:len(headings)-1]
+			}
+			if len(headings) > 0 {
+				parent = headings[len(headings)-1]
+			}
+			headings = append(headings, node)
+			items = nil
+		} else {
+			for len(items) > 0 && items[len(items)-1].indent >= node.indent {
+				items = items[:len(items)-1]
+			}
+			if len(items) > 0 {
+				parent = items[len(items)-1]
+			} else if len(headings) > 0 {
+				parent = headings[len(headings)-1]
+			}
+			items = append(items, node)
+		}
+
+		path := is.Title
+		if parent != nil {
+			path = strings.TrimPrefix(parent.issue.Ref.ID, file+"#") + "/" + is.Title
+			is.Parent = parent.issue.Ref.ID
+		}
+		if used[path]++; used[path] > 1 {
+			path += fmt.Sprintf(" (%d)", used[path]) // Same title twice under one parent
+		}
+		is.Ref = task.ExternalRef{Source: ImportMarkdown, ID: file + "#" + path}
+
+		if parent != nil {
+			parent.children = append(parent.children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	var issues []issue
+	var walk func(nodes []*planNode) (hasItems, allChecked bool)
+	walk = func(nodes []*planNode) (bool, bool) {
+		hasItems, allChecked := false, true
+		for _, n := range nodes {
+			childItems, childrenChecked := walk(n.children)
+			if n.heading > 0 {
+				if !childItems {
+					continue // A heading without items isn't work
+				}
+				n.issue.Closed = childrenChecked
+			}
+			hasItems = true
+			allChecked = allChecked && n.issue.Closed
+			issues = append(issues, *n.issue)
+		}
+		return hasItems, allChecked
+	}
+	walk(roots)
+
+	sortIssues(issues)
+	return issues
+}
+
+// rewriteMarkdownPlan appends a "(#id)" task reference to each imported
+// heading and item that doesn't carry the right one yet, and reports whether
+// the file changed
+func rewriteMarkdownPlan(path string, data []byte, issues []issue, result ImportResult) (bool, error) {
+	ids := map[string]int{}
+	for _, list := range [][]ImportedTask{result.Created, result.Updated, result.Unchanged} {
+		for _, t := range list {
+			ids[t.External] = t.ID
+		}
+	}
+
+	lines := strings.Split(string(data), "\n")
+	changed := false
+	for _, is := range issues {
+		id := ids[is.Ref.ID]
+		if id == 0 || id == is.TaskID {
+			continue
+		}
+
+		line := lines[is.Number-1]
+		cr := strings.HasSuffix(line, "\r")
+		line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " \t")
+		line = planRefPattern.ReplaceAllString(line, "")
+		line = fmt.Sprintf("%s (#%d)", line, id)
+		if cr {
+			line += "\r"
+		}
+		lines[is.Number-1] = line
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/onuse/tasks/internal/store"
+)
+
+// IndexCacheResult is the JSON result of the index-cache command
+type IndexCacheResult struct {
+	Enabled   bool   `json:"enabled"`
+	Path      string `json:"path"`      // Where the index is kept
+	Untracked bool   `json:"untracked"` // index.json was removed from git (enable)
+}
+
+// indexPathInRepo is the committed index, as git sees it
+const indexPathInRepo = store.TasksDir + "/" + store.IndexFile
+
+func IndexCache(args []string) error {
+	if len(args) < 1 {
+		return invalidArgf("usage: task index-cache <enable|disable|status>")
+	}
+
+	switch args[0] {
+	case "enable":
+		return indexCacheEnable(args[1:])
+	case "disable":
+		return indexCacheDisable(args[1:])
+	case "status":
+		return indexCacheStatus(args[1:])
+	default:
+		return invalidArgf("unknown index-cache subcommand '%s' (must be: enable, disable, status)", args[0])
+	}
+}
+
+// indexCacheEnable migrates an existing repository to the index cache: the
+// index moves to .tasks/cache/ and index.json is removed from git, leaving
+// the removal staged for the user to commit
+func indexCacheEnable(args []string) error {
+	fs := newFlagSet("index-cache enable")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	if err := s.EnableIndexCache(); err != nil {
+		return err
+	}
+
+	// Not being in a git repository, or index.json not being tracked, just
+	// means there is nothing to untrack
+	untracked := false
+	if _, err := runGit(s.Root(), "ls-files", "--error-unmatch", "--", indexPathInRepo); err == nil {
+		if _, err := runGit(s.Root(), "rm", "--cached", "--quiet", "--", indexPathInRepo); err != nil {
+			return err
+		}
+		untracked = true
+	}
+
+	path, err := s.IndexPath()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("index-cache", IndexCacheResult{Enabled: true, Path: path, Untracked: untracked})
+	}
+
+	fmt.Printf("Index cache enabled: the index is kept in %s and rebuilt when task files change\n", path)
+	if untracked {
+		fmt.Printf("Removed %s from git; commit with: git add .tasks && git commit -m \"Stop committing the task index\"\n", indexPathInRepo)
+	}
+	return nil
+}
+
+func indexCacheDisable(args []string) error {
+	fs := newFlagSet("index-cache disable")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	if err := s.DisableIndexCache(); err != nil {
+		return err
+	}
+
+	path, err := s.IndexPath()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("index-cache", IndexCacheResult{Enabled: false, Path: path})
+	}
+
+	fmt.Printf("Index cache disabled: the index is kept in %s\n", path)
+	fmt.Printf("Commit it with: git add .tasks && git commit -m \"Commit the task index\"\n")
+	return nil
+}
+
+func indexCacheStatus(args []string) error {
+	fs := newFlagSet("index-cache status")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	enabled, err := s.IndexCached()
+	if err != nil {
+		return err
+	}
+	path, err := s.IndexPath()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("index-cache", IndexCacheResult{Enabled: enabled, Path: path})
+	}
+
+	if enabled {
+		fmt.Printf("Index cache enabled (%s)\n", path)
+	} else {
+		fmt.Printf("Index cache disabled (%s)\n", path)
+	}
+	return nil
+}
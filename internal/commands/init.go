@@ -6,14 +6,38 @@ import (
 	"github.com/onuse/tasks/internal/store"
 )
 
-func Init() error {
+// InitResult is the JSON result of the init command
+type InitResult struct {
+	Path       string `json:"path"`
+	IndexCache bool   `json:"index_cache"`
+}
+
+func Init(args []string) error {
+	fs := newFlagSet("init")
+	indexCacheFlag := fs.Bool("index-cache", false, "Keep the index in a gitignored cache instead of committing it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
 	s := store.New(".")
 
 	if err := s.Init(); err != nil {
 		return err
 	}
+	if *indexCacheFlag {
+		if err := s.EnableIndexCache(); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput {
+		return writeResult("init", InitResult{Path: store.TasksDir, IndexCache: *indexCacheFlag})
+	}
 
 	fmt.Println("Initialized task tracking in .tasks/")
+	if *indexCacheFlag {
+		fmt.Println("The index is kept in .tasks/cache/, which is not committed")
+	}
 	fmt.Println("Add to git with: git add .tasks && git commit -m \"Initialize task tracking\"")
 	return nil
 }
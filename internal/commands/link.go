@@ -1,104 +1,151 @@
 package commands
 
 import (
-	"flag"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/onuse/tasks/internal/store"
 	"github.com/onuse/tasks/internal/task"
 )
 
+// LinkChange describes a link added or removed by link/unlink. An empty Type
+// on removal means all links between the two tasks were removed.
+type LinkChange struct {
+	SourceID int    `json:"source_id"`
+	TargetID int    `json:"target_id"`
+	Type     string `json:"type,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+// LinkResult is the JSON result of the link command
+type LinkResult struct {
+	Links []LinkChange `json:"links"`
+}
+
 func Link(args []string) error {
 	if len(args) < 2 {
-		return fmt.Errorf("usage: task link <id> <target_id> [--type TYPE] [--label LABEL] [--bidirectional]")
+		return invalidArgf("usage: task link <id> <target_id> [--type TYPE] [--label LABEL] [--bidirectional]")
 	}
 
 	// Parse source and target IDs
-	sourceID, err := strconv.Atoi(args[0])
+	sourceID, err := parseTaskID(args[0], "source task ID")
 	if err != nil {
-		return fmt.Errorf("invalid source task ID '%s'", args[0])
+		return err
 	}
 
-	targetID, err := strconv.Atoi(args[1])
+	targetID, err := parseTaskID(args[1], "target task ID")
 	if err != nil {
-		return fmt.Errorf("invalid target task ID '%s'", args[1])
-	}
-
-	if sourceID == targetID {
-		return fmt.Errorf("cannot link a task to itself")
+		return err
 	}
 
 	// Parse flags
-	fs := flag.NewFlagSet("link", flag.ExitOnError)
+	fs := newFlagSet("link")
 	linkType := fs.String("type", task.LinkTypeRelatesTo, "Link type (blocks, blocked_by, parent, child, relates_to, duplicates)")
 	label := fs.String("label", "", "Optional custom label for the link")
 	bidirectional := fs.Bool("bidirectional", false, "Create reciprocal link")
-	fs.Parse(args[2:])
+	if err := parseFlags(fs, args[2:]); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	links, err := linkTasks(s, sourceID, targetID, *linkType, *label, *bidirectional)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("link", LinkResult{Links: links})
+	}
+
+	fmt.Printf("Linked task #%d to #%d (%s)\n", sourceID, targetID, *linkType)
+	if len(links) > 1 {
+		fmt.Printf("Created reciprocal link: task #%d to #%d (%s)\n", links[1].SourceID, links[1].TargetID, links[1].Type)
+	}
+	return nil
+}
+
+// linkTasks links source to target, and target back to source when
+// bidirectional is set. It is shared by the CLI and the web API.
+func linkTasks(s *store.Store, sourceID, targetID int, linkType, label string, bidirectional bool) ([]LinkChange, error) {
+	if sourceID == targetID {
+		return nil, invalidArgf("cannot link a task to itself")
 	}
 
-	s := store.New(rootDir)
+	if linkType == "" {
+		linkType = task.LinkTypeRelatesTo
+	}
 
 	// Read source task
 	sourceTask, err := s.ReadTask(sourceID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	sourceBefore := sourceTask.Clone()
 
 	// Verify target task exists
 	_, err = s.ReadTask(targetID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Add link
-	sourceTask.AddLink(targetID, *linkType, *label)
+	sourceTask.AddLink(targetID, linkType, label)
 	sourceTask.Updated = time.Now()
 
 	if err := s.WriteTask(sourceTask); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Rebuild index
 	if err := s.RebuildIndex(); err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Printf("Linked task #%d to #%d (%s)\n", sourceID, targetID, *linkType)
+	links := []LinkChange{{SourceID: sourceID, TargetID: targetID, Type: linkType, Label: label}}
 
 	// Handle bidirectional linking
-	if *bidirectional {
-		reciprocalType := getReciprocalLinkType(*linkType)
+	var targetBefore, targetTask *task.Task
+	if bidirectional {
+		reciprocalType := getReciprocalLinkType(linkType)
 
-		targetTask, err := s.ReadTask(targetID)
+		targetTask, err = s.ReadTask(targetID)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		targetBefore = targetTask.Clone()
 
-		targetTask.AddLink(sourceID, reciprocalType, *label)
+		targetTask.AddLink(sourceID, reciprocalType, label)
 		targetTask.Updated = time.Now()
 
 		if err := s.WriteTask(targetTask); err != nil {
-			return err
+			return nil, err
 		}
 
 		if err := s.RebuildIndex(); err != nil {
-			return err
+			return nil, err
 		}
 
-		fmt.Printf("Created reciprocal link: task #%d to #%d (%s)\n", targetID, sourceID, reciprocalType)
+		links = append(links, LinkChange{SourceID: targetID, TargetID: sourceID, Type: reciprocalType, Label: label})
 	}
 
-	return nil
+	events := make([]task.SessionEvent, len(links))
+	for i, link := range links {
+		events[i] = task.SessionEvent{TaskID: link.SourceID, Action: task.ActionLink, To: fmt.Sprintf("%s #%d", link.Type, link.TargetID)}
+	}
+	if err := recordActivity(s, events...); err != nil {
+		return nil, err
+	}
+
+	notifyWebhooks(s, EventTaskLinked, sourceBefore, sourceTask, links[0])
+	if targetTask != nil {
+		notifyWebhooks(s, EventTaskLinked, targetBefore, targetTask, links[1])
+	}
+
+	return links, nil
 }
 
 // getReciprocalLinkType returns the reciprocal link type
@@ -2,39 +2,40 @@ package commands
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
-	"os"
+	"slices"
 	"sort"
 
-	"github.com/onuse/tasks/internal/store"
 	"github.com/onuse/tasks/internal/task"
 )
 
+// ListResult is the JSON result of the list command
+type ListResult struct {
+	Tasks []task.IndexEntry `json:"tasks"`
+	Count int               `json:"count"`
+}
+
 func List(args []string) error {
 	// Parse flags
-	fs := flag.NewFlagSet("list", flag.ExitOnError)
-	statusFlag := fs.String("status", "active", "Filter by status (backlog, next, active, blocked, done, cancelled, label, all)")
+	fs := newFlagSet("list")
+	statusFlag := fs.String("status", "active", "Filter by status (a workflow status, label, or all)")
 	formatFlag := fs.String("format", "text", "Output format (text, json, compact)")
 	sortFlag := fs.String("sort", "id", "Sort by: id, created, updated, title, status")
 	reverseFlag := fs.Bool("reverse", false, "Reverse sort order")
-	fs.Parse(args)
-
-	// Validate status
-	filterStatus := *statusFlag
-	if filterStatus != "all" && !task.IsValidStatus(filterStatus) {
-		return fmt.Errorf("invalid status '%s' (must be: backlog, next, active, blocked, done, cancelled, label, all)", filterStatus)
+	if err := parseFlags(fs, args); err != nil {
+		return err
 	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	s, err := openStore()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
 	}
 
-	s := store.New(rootDir)
+	// Validate status against the repository's workflow
+	filterStatus := *statusFlag
+	if err := validateStatusFilter(filterStatus); err != nil {
+		return err
+	}
 
 	// Read index
 	index, err := s.ReadIndex()
@@ -43,17 +44,19 @@ func List(args []string) error {
 	}
 
 	// Filter tasks
-	var filtered []task.IndexEntry
-	for _, entry := range index.Tasks {
-		if filterStatus == "all" || string(entry.Status) == filterStatus {
-			filtered = append(filtered, entry)
-		}
-	}
+	filtered := filterByStatus(index.Tasks, filterStatus)
 
 	// Sort tasks
 	sortTasks(filtered, *sortFlag, *reverseFlag)
 
 	// Output
+	if jsonOutput {
+		if filtered == nil {
+			filtered = []task.IndexEntry{}
+		}
+		return writeResult("list", ListResult{Tasks: filtered, Count: len(filtered)})
+	}
+
 	switch *formatFlag {
 	case "json":
 		return outputJSON(filtered)
@@ -64,38 +67,40 @@ func List(args []string) error {
 	}
 }
 
+// sortTasks sorts index entries in place. The sort is stable, so entries with
+// equal keys keep their (ID) order and pages of API results don't overlap.
 func sortTasks(tasks []task.IndexEntry, sortBy string, reverse bool) {
 	switch sortBy {
 	case "created":
-		sort.Slice(tasks, func(i, j int) bool {
+		sort.SliceStable(tasks, func(i, j int) bool {
 			if reverse {
 				return tasks[i].Created.After(tasks[j].Created)
 			}
 			return tasks[i].Created.Before(tasks[j].Created)
 		})
 	case "updated":
-		sort.Slice(tasks, func(i, j int) bool {
+		sort.SliceStable(tasks, func(i, j int) bool {
 			if reverse {
 				return tasks[i].Updated.After(tasks[j].Updated)
 			}
 			return tasks[i].Updated.Before(tasks[j].Updated)
 		})
 	case "title":
-		sort.Slice(tasks, func(i, j int) bool {
+		sort.SliceStable(tasks, func(i, j int) bool {
 			if reverse {
 				return tasks[i].Title > tasks[j].Title
 			}
 			return tasks[i].Title < tasks[j].Title
 		})
 	case "status":
-		sort.Slice(tasks, func(i, j int) bool {
+		sort.SliceStable(tasks, func(i, j int) bool {
 			if reverse {
-				return tasks[i].Status > tasks[j].Status
+				return statusRank(tasks[i].Status) > statusRank(tasks[j].Status)
 			}
-			return tasks[i].Status < tasks[j].Status
+			return statusRank(tasks[i].Status) < statusRank(tasks[j].Status)
 		})
 	default: // "id"
-		sort.Slice(tasks, func(i, j int) bool {
+		sort.SliceStable(tasks, func(i, j int) bool {
 			if reverse {
 				return tasks[i].ID > tasks[j].ID
 			}
@@ -104,6 +109,17 @@ func sortTasks(tasks []task.IndexEntry, sortBy string, reverse bool) {
 	}
 }
 
+// statusRank returns a status's position in the workflow, which is the order
+// of the board's columns. Statuses the workflow doesn't define sort last.
+func statusRank(status task.Status) int {
+	statuses := task.CurrentWorkflow().Statuses
+	rank := slices.IndexFunc(statuses, func(s task.WorkflowStatus) bool { return s.Name == status })
+	if rank < 0 {
+		return len(statuses)
+	}
+	return rank
+}
+
 func outputText(tasks []task.IndexEntry) error {
 	if len(tasks) == 0 {
 		fmt.Println("No tasks found")
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+func TestSortTasksByStatusFollowsWorkflow(t *testing.T) {
+	workflow := &task.Workflow{Statuses: []task.WorkflowStatus{
+		{Name: "todo", Category: task.CategoryOpen},
+		{Name: "review", Category: task.CategoryOpen},
+		{Name: "shipped", Category: task.CategoryClosed},
+	}}
+	task.SetWorkflow(workflow)
+	t.Cleanup(func() { task.SetWorkflow(nil) })
+
+	entries := []task.IndexEntry{
+		{ID: 1, Status: "shipped"},
+		{ID: 2, Status: "unknown"},
+		{ID: 3, Status: "review"},
+		{ID: 4, Status: "todo"},
+		{ID: 5, Status: "review"},
+	}
+
+	sortTasks(entries, "status", false)
+	if got, want := entryIDs(entries), []int{4, 3, 5, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("sorted by status: %v, want %v", got, want)
+	}
+
+	sortTasks(entries, "status", true)
+	if got, want := entryIDs(entries), []int{2, 1, 3, 5, 4}; !slices.Equal(got, want) {
+		t.Errorf("sorted by status, reversed: %v, want %v", got, want)
+	}
+}
+
+func entryIDs(entries []task.IndexEntry) []int {
+	ids := make([]int, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
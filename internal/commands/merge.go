@@ -1,80 +1,108 @@
 package commands
 
 import (
-	"flag"
+	"errors"
 	"fmt"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/onuse/tasks/internal/store"
 	"github.com/onuse/tasks/internal/task"
 )
 
+// mergedPrefix marks the description of a task that was merged into another
+const mergedPrefix = "[MERGED INTO #"
+
+// MergeResult is the JSON result of the merge command
+type MergeResult struct {
+	SourceID          int `json:"source_id"`
+	TargetID          int `json:"target_id"`
+	ReferencesUpdated int `json:"references_updated"`
+}
+
 func Merge(args []string) error {
-	fs := flag.NewFlagSet("merge", flag.ExitOnError)
-	fs.Parse(args)
+	fs := newFlagSet("merge")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
 
 	if fs.NArg() < 2 {
-		return fmt.Errorf("usage: task merge <source_id> <target_id>")
+		return invalidArgf("usage: task merge <source_id> <target_id>")
 	}
 
 	// Parse IDs
-	sourceID, err := strconv.Atoi(fs.Arg(0))
+	sourceID, err := parseTaskID(fs.Arg(0), "source ID")
 	if err != nil {
-		return fmt.Errorf("invalid source ID '%s'", fs.Arg(0))
+		return err
 	}
 
-	targetID, err := strconv.Atoi(fs.Arg(1))
+	targetID, err := parseTaskID(fs.Arg(1), "target ID")
 	if err != nil {
-		return fmt.Errorf("invalid target ID '%s'", fs.Arg(1))
+		return err
 	}
 
-	if sourceID == targetID {
-		return fmt.Errorf("source and target cannot be the same task")
+	s, err := openStore()
+	if err != nil {
+		return err
 	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	result, err := mergeTasks(s, sourceID, targetID)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("merge", result)
 	}
 
-	s := store.New(rootDir)
+	fmt.Printf("Merged task #%d into #%d\n", sourceID, targetID)
+	fmt.Printf("Updated %d task(s) that referenced #%d\n", result.ReferencesUpdated, sourceID)
+	fmt.Printf("Source task #%d marked as cancelled\n", sourceID)
+
+	return nil
+}
+
+// mergeTasks merges source into target: references to source are redirected,
+// links and notes are copied and source is cancelled. It is shared by the CLI
+// and the web API.
+func mergeTasks(s *store.Store, sourceID, targetID int) (MergeResult, error) {
+	if sourceID == targetID {
+		return MergeResult{}, invalidArgf("source and target cannot be the same task")
+	}
 
 	// Read both tasks
 	sourceTask, err := s.ReadTask(sourceID)
-	if err != nil {
-		return fmt.Errorf("source task #%d not found", sourceID)
+	if errors.Is(err, store.ErrNotFound) {
+		return MergeResult{}, notFoundf("source task #%d not found", sourceID)
+	} else if err != nil {
+		return MergeResult{}, err
 	}
 
 	targetTask, err := s.ReadTask(targetID)
-	if err != nil {
-		return fmt.Errorf("target task #%d not found", targetID)
+	if errors.Is(err, store.ErrNotFound) {
+		return MergeResult{}, notFoundf("target task #%d not found", targetID)
+	} else if err != nil {
+		return MergeResult{}, err
 	}
 
-	// Read index to find all tasks
-	index, err := s.ReadIndex()
-	if err != nil {
-		return err
+	if strings.HasPrefix(sourceTask.Description, mergedPrefix) {
+		return MergeResult{}, newError(ErrCodeConflict, "task #%d has already been merged", sourceID)
 	}
 
-	tasksUpdated := 0
+	sourceBefore, targetBefore := sourceTask.Clone(), targetTask.Clone()
 
-	// Update all tasks that link to source
-	for _, entry := range index.Tasks {
-		if entry.ID == sourceID || entry.ID == targetID {
-			continue // Skip source and target themselves
-		}
+	// Tasks whose links were redirected, as they were before and after
+	var referrersBefore, referrers []*task.Task
 
-		t, err := s.ReadTask(entry.ID)
-		if err != nil {
-			continue
+	// Update all tasks that link to source, archived ones included
+	err = s.ForEachTask(func(t *task.Task) error {
+		if t.ID == sourceID || t.ID == targetID {
+			return nil // Skip source and target themselves
 		}
 
 		modified := false
+		before := t.Clone()
 
 		// Update links pointing to source
 		for i := range t.Links {
@@ -89,8 +117,13 @@ func Merge(args []string) error {
 			if err := s.WriteTask(t); err != nil {
 				return err
 			}
-			tasksUpdated++
+			referrersBefore = append(referrersBefore, before)
+			referrers = append(referrers, t)
 		}
+		return nil
+	})
+	if err != nil {
+		return MergeResult{}, err
 	}
 
 	// Merge source links into target
@@ -110,26 +143,44 @@ func Merge(args []string) error {
 
 	// Save target task
 	if err := s.WriteTask(targetTask); err != nil {
-		return err
+		return MergeResult{}, err
 	}
 
 	// Cancel source task
+	now := time.Now()
 	sourceTask.Status = task.StatusCancelled
-	sourceTask.Updated = time.Now()
-	sourceTask.Description = fmt.Sprintf("[MERGED INTO #%d] %s", targetID, sourceTask.Description)
+	sourceTask.StatusBy = currentAgent()
+	sourceTask.StatusSince = &now
+	sourceTask.Completed = &now
+	sourceTask.Updated = now
+	sourceTask.Description = fmt.Sprintf("%s%d] %s", mergedPrefix, targetID, sourceTask.Description)
 
 	if err := s.WriteTask(sourceTask); err != nil {
-		return err
+		return MergeResult{}, err
 	}
 
 	// Rebuild index
 	if err := s.RebuildIndex(); err != nil {
-		return err
+		return MergeResult{}, err
 	}
 
-	fmt.Printf("Merged task #%d into #%d\n", sourceID, targetID)
-	fmt.Printf("Updated %d task(s) that referenced #%d\n", tasksUpdated, sourceID)
-	fmt.Printf("Source task #%d marked as cancelled\n", sourceID)
+	if err := recordActivity(s,
+		task.SessionEvent{TaskID: sourceID, Action: task.ActionMerge, To: strconv.Itoa(targetID)},
+		task.SessionEvent{TaskID: targetID, Action: task.ActionMerge, From: strconv.Itoa(sourceID)},
+	); err != nil {
+		return MergeResult{}, err
+	}
 
-	return nil
+	result := MergeResult{
+		SourceID:          sourceID,
+		TargetID:          targetID,
+		ReferencesUpdated: len(referrers),
+	}
+	notifyWebhooks(s, EventTaskMerged, sourceBefore, sourceTask, result)
+	notifyWebhooks(s, EventTaskMerged, targetBefore, targetTask, result)
+	for i, t := range referrers {
+		notifyWebhooks(s, EventTaskUpdated, referrersBefore[i], t, result)
+	}
+
+	return result, nil
 }
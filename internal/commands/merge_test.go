@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+func TestMergeTasksReadErrors(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := createTask(s, "Target", ""); err != nil {
+		t.Fatalf("createTask: %v", err)
+	}
+
+	var cmdErr *CommandError
+	if _, err := mergeTasks(s, 99, 2); !errors.As(err, &cmdErr) || cmdErr.Code != ErrCodeNotFound {
+		t.Errorf("merging a missing task = %v, want not_found", err)
+	}
+
+	// A damaged file is not a missing task
+	path := filepath.Join(s.Root(), store.TasksDir, store.TasksSubDir, "00001.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeTasks(s, 1, 2); classifyError(err).Code != ErrCodeCorruptData {
+		t.Errorf("merging a corrupt task = %v, want corrupt_data", err)
+	}
+}
+
+func TestMergeTasksNotifiesRedirectedTasks(t *testing.T) {
+	var mu sync.Mutex
+	var events []WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		events = append(events, payload)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	s := newTestStore(t)
+	for _, title := range []string{"Target", "Referrer"} {
+		if _, err := createTask(s, title, ""); err != nil {
+			t.Fatalf("createTask: %v", err)
+		}
+	}
+	if _, err := linkTasks(s, 3, 1, task.LinkTypeRelatesTo, "", false); err != nil {
+		t.Fatalf("linkTasks: %v", err)
+	}
+	config, err := s.ReadConfig()
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+	config.Webhooks = []task.Webhook{{URL: server.URL, Events: []string{EventTaskUpdated}}}
+	if err := s.WriteConfig(config); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+
+	if _, err := mergeTasks(s, 1, 2); err != nil {
+		t.Fatalf("mergeTasks: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0].TaskID != 3 {
+		t.Fatalf("task.updated events %+v, want one for #3", events)
+	}
+	if links := events[0].After.Links; len(links) != 1 || links[0].TargetID != 2 {
+		t.Errorf("#3 links after the merge = %+v, want one to #2", links)
+	}
+}
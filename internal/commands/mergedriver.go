@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// mergeDriverName is the name the driver is registered under in git config
+// and .gitattributes ("merge=task")
+const mergeDriverName = "task"
+
+// mergeDriverAuthor is the author of conflict notes
+const mergeDriverAuthor = "merge-driver"
+
+// mergeDriverAttributes are the .gitattributes lines that route .tasks files
+// to the driver
+var mergeDriverAttributes = []string{
+	store.TasksDir + "/" + store.ManifestFile + " merge=" + mergeDriverName,
+	store.TasksDir + "/" + store.IndexFile + " merge=" + mergeDriverName,
+	store.TasksDir + "/" + store.TasksSubDir + "/*.json merge=" + mergeDriverName,
+	store.TasksDir + "/" + store.ArchiveSubDir + "/*.json merge=" + mergeDriverName,
+}
+
+// MergeDriverInstallResult is the JSON result of merge-driver install
+type MergeDriverInstallResult struct {
+	Attributes string   `json:"attributes"` // Path of .gitattributes
+	Added      []string `json:"added"`      // Lines added to it
+	Driver     string   `json:"driver"`     // Command registered in git config
+}
+
+// MergeDriver merges .tasks JSON files for git. Git runs it as
+// "task merge-driver %O %A %B %P" with the common ancestor, our and their
+// versions and the file's path; the result replaces our version.
+func MergeDriver(args []string) error {
+	fs := newFlagSet("merge-driver")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 1 && fs.Arg(0) == "install" {
+		return mergeDriverInstall()
+	}
+	if fs.NArg() != 4 {
+		return invalidArgf("usage: task merge-driver install, or task merge-driver <base> <ours> <theirs> <path> (run by git)")
+	}
+	basePath, oursPath, theirsPath, filePath := fs.Arg(0), fs.Arg(1), fs.Arg(2), fs.Arg(3)
+
+	var merged any
+	var conflicts []task.MergeConflict
+	var err error
+	switch path.Base(filepath.ToSlash(filePath)) {
+	case store.ManifestFile:
+		merged, err = mergeManifestFiles(oursPath, theirsPath)
+	case store.IndexFile:
+		merged, err = mergeIndexFiles(oursPath)
+	default:
+		merged, conflicts, err = mergeTaskFiles(basePath, oursPath, theirsPath, filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(oursPath, data, 0644); err != nil {
+		return err
+	}
+
+	for _, c := range conflicts {
+		fmt.Printf("%s: both sides changed %s, kept the newer value\n", filePath, c.Field)
+	}
+	return nil
+}
+
+// readMergeFile decodes one version of a file. A missing or empty base means
+// the file was added on both sides, which is reported as false.
+func readMergeFile(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, newError(ErrCodeCorruptData, "invalid JSON: %v", err)
+	}
+	return true, nil
+}
+
+func mergeManifestFiles(oursPath, theirsPath string) (*task.Manifest, error) {
+	var ours, theirs task.Manifest
+	if _, err := readMergeFile(oursPath, &ours); err != nil {
+		return nil, err
+	}
+	if _, err := readMergeFile(theirsPath, &theirs); err != nil {
+		return nil, err
+	}
+
+	// IDs handed out on either branch stay taken
+	ours.NextID = max(ours.NextID, theirs.NextID)
+	return &ours, nil
+}
+
+// mergeIndexFiles marks our index as stale, so the first command after the
+// merge rebuilds it from the merged task files. The driver can't build it
+// itself: git merges task files in any order and writes them out last, and
+// files changed on one side never reach the driver.
+func mergeIndexFiles(oursPath string) (*task.Index, error) {
+	var ours task.Index
+	if _, err := readMergeFile(oursPath, &ours); err != nil {
+		return nil, err
+	}
+	ours.Stale = true
+	return &ours, nil
+}
+
+// mergeTaskFiles merges a task file field by field. Fields both sides
+// changed keep the newer value, and a note records the discarded one.
+func mergeTaskFiles(basePath, oursPath, theirsPath, filePath string) (*task.Task, []task.MergeConflict, error) {
+	var base, ours, theirs task.Task
+	hasBase, err := readMergeFile(basePath, &base)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := readMergeFile(oursPath, &ours); err != nil {
+		return nil, nil, err
+	}
+	if _, err := readMergeFile(theirsPath, &theirs); err != nil {
+		return nil, nil, err
+	}
+
+	basePtr := &base
+	if !hasBase {
+		// Both branches created a task with this ID. They are different
+		// tasks, so merging them would lose one.
+		if !ours.Created.Equal(theirs.Created) {
+			return nil, nil, fmt.Errorf("task #%d was created on both branches; keeping ours, recreate theirs from 'git show MERGE_HEAD:%s'", ours.ID, filepath.ToSlash(filePath))
+		}
+		basePtr = nil
+	}
+
+	merged, conflicts := task.MergeTask(basePtr, &ours, &theirs)
+	for _, c := range conflicts {
+		merged.Notes = append(merged.Notes, task.Note{
+			Timestamp: time.Now(),
+			Author:    mergeDriverAuthor,
+			Text:      fmt.Sprintf("Merge conflict on %s: kept %s, discarded %s", c.Field, conflictValue(c.Kept), conflictValue(c.Discarded)),
+		})
+	}
+
+	// MergeTask keeps the later of both update times. A result that matches
+	// neither side is a new version of the task, so it is stamped now, or
+	// ETags of one side's version would match the merged one.
+	if !sameJSON(merged, &ours) && !sameJSON(merged, &theirs) {
+		merged.Updated = time.Now()
+	}
+	return merged, conflicts, nil
+}
+
+// sameJSON reports whether two values encode to the same JSON
+func sameJSON(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// conflictValue formats a field value for a conflict note
+func conflictValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" || string(data) == `""` {
+		return "(none)"
+	}
+	return string(data)
+}
+
+// mergeDriverInstall registers the driver in the repository's git config
+// and routes .tasks files to it in .gitattributes
+func mergeDriverInstall() error {
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	driver := "task merge-driver %O %A %B %P"
+	if _, err := runGit(s.Root(), "config", "merge."+mergeDriverName+".name", "task JSON merge driver"); err != nil {
+		return err
+	}
+	if _, err := runGit(s.Root(), "config", "merge."+mergeDriverName+".driver", driver); err != nil {
+		return err
+	}
+
+	attributes := filepath.Join(s.Root(), ".gitattributes")
+	data, err := os.ReadFile(attributes)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	content := string(data)
+	added := []string{}
+	for _, line := range mergeDriverAttributes {
+		if existing[line] {
+			continue
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += line + "\n"
+		added = append(added, line)
+	}
+	if len(added) > 0 {
+		if err := os.WriteFile(attributes, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	result := MergeDriverInstallResult{Attributes: attributes, Added: added, Driver: driver}
+	if jsonOutput {
+		return writeResult("merge-driver", result)
+	}
+
+	fmt.Printf("Registered merge driver '%s' in git config: %s\n", mergeDriverName, driver)
+	if len(added) == 0 {
+		fmt.Printf("%s already routes .tasks files to the driver\n", attributes)
+	} else {
+		fmt.Printf("Added to %s:\n", attributes)
+		for _, line := range added {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return nil
+}
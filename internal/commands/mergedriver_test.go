@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// writeMergeFile writes one version of a file for the merge driver
+func writeMergeFile(t *testing.T, dir, name string, v any) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMergeTaskFilesUpdated(t *testing.T) {
+	t0 := time.Date(2025, 11, 3, 10, 0, 0, 0, time.UTC)
+	t1, t2 := t0.Add(time.Hour), t0.Add(2*time.Hour)
+	base := task.Task{ID: 1, Created: t0, Updated: t0, Status: task.StatusBacklog, Title: "Base",
+		Notes: []task.Note{}, Links: []task.TaskLink{}, Dependencies: []int{}, Tags: []string{}}
+
+	tests := []struct {
+		name         string
+		ours, theirs func(*task.Task)
+		wantUpdated  time.Time // Zero: the time of the merge
+	}{
+		{
+			name:        "only theirs changed",
+			ours:        func(c *task.Task) {},
+			theirs:      func(c *task.Task) { c.Updated = t2; c.Status = task.StatusActive },
+			wantUpdated: t2,
+		},
+		{
+			name:        "only ours changed",
+			ours:        func(c *task.Task) { c.Updated = t2; c.Title = "Ours" },
+			theirs:      func(c *task.Task) {},
+			wantUpdated: t2,
+		},
+		{
+			name:   "both changed different fields",
+			ours:   func(c *task.Task) { c.Updated = t1; c.Title = "Ours" },
+			theirs: func(c *task.Task) { c.Updated = t2; c.Status = task.StatusActive },
+		},
+		{
+			name:   "both changed the same field",
+			ours:   func(c *task.Task) { c.Updated = t2; c.Title = "Ours" },
+			theirs: func(c *task.Task) { c.Updated = t1; c.Title = "Theirs" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ours, theirs := base, base
+			tt.ours(&ours)
+			tt.theirs(&theirs)
+
+			start := time.Now()
+			merged, _, err := mergeTaskFiles(
+				writeMergeFile(t, dir, "base", base),
+				writeMergeFile(t, dir, "ours", ours),
+				writeMergeFile(t, dir, "theirs", theirs),
+				".tasks/tasks/00001.json",
+			)
+			if err != nil {
+				t.Fatalf("mergeTaskFiles: %v", err)
+			}
+
+			if tt.wantUpdated.IsZero() {
+				if merged.Updated.Before(start) {
+					t.Errorf("updated %v, want the time of the merge", merged.Updated)
+				}
+			} else if !merged.Updated.Equal(tt.wantUpdated) {
+				t.Errorf("updated %v, want %v", merged.Updated, tt.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestMergedIndexIsRebuilt(t *testing.T) {
+	root := t.TempDir()
+	s := store.New(root)
+	if err := s.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := s.WriteTask(&task.Task{ID: 1, Status: task.StatusActive, Title: "Merged"}); err != nil {
+		t.Fatalf("WriteTask: %v", err)
+	}
+
+	// Our side's index doesn't know about the task from their branch
+	indexPath := filepath.Join(root, store.TasksDir, store.IndexFile)
+	merged, err := mergeIndexFiles(indexPath)
+	if err != nil {
+		t.Fatalf("mergeIndexFiles: %v", err)
+	}
+	if !merged.Stale {
+		t.Fatal("merged index is not marked stale")
+	}
+	writeMergeFile(t, filepath.Dir(indexPath), store.IndexFile, merged)
+
+	index, err := s.ReadIndex()
+	if err != nil {
+		t.Fatalf("ReadIndex: %v", err)
+	}
+	if index.Stale || len(index.Tasks) != 1 || index.Tasks[0].Title != "Merged" {
+		t.Errorf("ReadIndex = %+v, want it rebuilt from the task files", index)
+	}
+}
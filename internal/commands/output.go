@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// ResultVersion is the version of the JSON result envelope. Bump it when a
+// field is removed or changes meaning; adding fields is backward compatible.
+const ResultVersion = 1
+
+// jsonOutput is set by the global --json flag
+var jsonOutput bool
+
+// SetJSONOutput enables or disables machine-readable output for all commands
+func SetJSONOutput(enabled bool) {
+	jsonOutput = enabled
+}
+
+// Result is the envelope every command writes to stdout in JSON mode
+type Result struct {
+	Version int          `json:"version"`
+	Command string       `json:"command"`
+	OK      bool         `json:"ok"`
+	Data    interface{}  `json:"data,omitempty"`
+	Error   *ErrorResult `json:"error,omitempty"`
+}
+
+// ErrorResult describes a failed command in JSON mode
+type ErrorResult struct {
+	Code     string      `json:"code"`
+	Message  string      `json:"message"`
+	Hint     string      `json:"hint,omitempty"`
+	ExitCode int         `json:"exit_code"`
+	Data     interface{} `json:"data,omitempty"` // Command specific details
+}
+
+// FieldChange records a single field modified by a command
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+}
+
+// writeResult writes a successful result envelope
+func writeResult(command string, data interface{}) error {
+	return writeJSON(Result{
+		Version: ResultVersion,
+		Command: command,
+		OK:      true,
+		Data:    data,
+	})
+}
+
+func writeJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// ReportError prints err for the given command and returns the process exit code
+func ReportError(command string, err error) int {
+	if !jsonOutput && errors.Is(err, flag.ErrHelp) {
+		return ExitOK // Usage was already printed by the flag package
+	}
+
+	cmdErr := classifyError(err)
+
+	if jsonOutput {
+		writeJSON(Result{
+			Version: ResultVersion,
+			Command: command,
+			OK:      false,
+			Error: &ErrorResult{
+				Code:     cmdErr.Code,
+				Message:  cmdErr.Message,
+				Hint:     cmdErr.Hint,
+				ExitCode: cmdErr.ExitCode,
+				Data:     cmdErr.Data,
+			},
+		})
+		return cmdErr.ExitCode
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %s\n", cmdErr.Message)
+	if cmdErr.Hint != "" {
+		fmt.Fprintln(os.Stderr, cmdErr.Hint)
+	}
+	return cmdErr.ExitCode
+}
+
+// UsageError is reported by main when no command is given
+func UsageError() error {
+	return invalidArgf("usage: task <command> [options]")
+}
+
+// UnknownCommandError is returned by main for unrecognised commands
+func UnknownCommandError(command string) error {
+	return newError(ErrCodeUnknownCommand, "unknown command '%s'", command)
+}
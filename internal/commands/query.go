@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"encoding/base64"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// maxPageSize caps the limit parameter of GET /api/tasks
+const maxPageSize = 1000
+
+// validSortFields are the fields accepted by sortTasks
+var validSortFields = []string{"id", "created", "updated", "title", "status"}
+
+// TaskQuery selects, orders and pages index entries for GET /api/tasks
+type TaskQuery struct {
+	Status   string // Status name or "all"
+	Tag      string // Label name
+	Text     string // Matched like the search command, or against the ID
+	NoteKind string // Only tasks with a note of this kind
+	Sort     string
+	Reverse  bool
+	Limit    int // 0 returns every remaining entry
+	Offset   int
+}
+
+// TaskPage is one page of GET /api/tasks results
+type TaskPage struct {
+	Tasks      []task.IndexEntry `json:"tasks"`
+	Total      int               `json:"total"`                 // Matches across all pages
+	NextCursor string            `json:"next_cursor,omitempty"` // Pass as ?cursor= for the next page
+}
+
+// parseTaskQuery reads a TaskQuery from URL query parameters
+func parseTaskQuery(values url.Values) (TaskQuery, error) {
+	q := TaskQuery{
+		Status:   values.Get("status"),
+		Tag:      values.Get("tag"),
+		Text:     strings.ToLower(strings.TrimSpace(values.Get("q"))),
+		NoteKind: values.Get("note_kind"),
+		Sort:     values.Get("sort"),
+	}
+
+	if q.Status == "" {
+		q.Status = "all"
+	}
+	if err := validateStatusFilter(q.Status); err != nil {
+		return q, err
+	}
+
+	if q.NoteKind != "" && !task.IsValidNoteKind(q.NoteKind) {
+		return q, invalidArgf("invalid note kind '%s' (must be: %s)", q.NoteKind, strings.Join(task.ValidNoteKinds(), ", "))
+	}
+
+	if q.Sort == "" {
+		q.Sort = "id"
+	}
+	if !slices.Contains(validSortFields, q.Sort) {
+		return q, invalidArgf("invalid sort '%s' (must be: %s)", q.Sort, strings.Join(validSortFields, ", "))
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Reverse = true
+	default:
+		return q, invalidArgf("invalid order '%s' (must be: asc, desc)", values.Get("order"))
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return q, invalidArgf("invalid limit '%s' (must be 1-%d)", limit, maxPageSize)
+		}
+		q.Limit = n
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			return q, invalidArgf("invalid cursor '%s'", cursor)
+		}
+		q.Offset = offset
+	}
+
+	return q, nil
+}
+
+// queryTasks filters, sorts and pages the index. Task files are only read
+// when a filter needs more than the index holds.
+func queryTasks(s *store.Store, index *task.Index, q TaskQuery) (TaskPage, error) {
+	entries := filterByStatus(index.Tasks, q.Status)
+
+	labelID := 0
+	if q.Tag != "" {
+		label, err := findLabelByName(s, q.Tag)
+		if err != nil {
+			return TaskPage{Tasks: []task.IndexEntry{}}, nil // Unknown tag matches nothing
+		}
+		labelID = label.ID
+	}
+
+	if q.Tag != "" || q.Text != "" || q.NoteKind != "" {
+		var matched []task.IndexEntry
+		for _, entry := range entries {
+			t, err := s.ReadTask(entry.ID)
+			if err != nil {
+				continue // Skip tasks we can't read
+			}
+			if labelID != 0 && !t.HasLink(labelID, task.LinkTypeChild) {
+				continue
+			}
+			if q.Text != "" && strconv.Itoa(t.ID) != strings.TrimPrefix(q.Text, "#") && !matchesQuery(t, q.Text) {
+				continue
+			}
+			if q.NoteKind != "" && len(t.NotesOfKind(q.NoteKind)) == 0 {
+				continue
+			}
+			matched = append(matched, entry)
+		}
+		entries = matched
+	}
+
+	sortTasks(entries, q.Sort, q.Reverse)
+
+	page := TaskPage{Total: len(entries)}
+	start := min(q.Offset, len(entries))
+	end := len(entries)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+		page.NextCursor = encodeCursor(end)
+	}
+
+	page.Tasks = entries[start:end]
+	if page.Tasks == nil {
+		page.Tasks = []task.IndexEntry{}
+	}
+	return page, nil
+}
+
+// validateStatusFilter checks a status filter as accepted by list
+func validateStatusFilter(status string) error {
+	if status != "all" && !task.IsValidStatus(status) {
+		return invalidArgf("invalid status '%s' (must be: %s, all)", status, statusNames())
+	}
+	return nil
+}
+
+// statusNames lists the valid statuses for error messages
+func statusNames() string {
+	var names []string
+	for _, status := range task.ValidStatuses() {
+		names = append(names, string(status))
+	}
+	return strings.Join(names, ", ")
+}
+
+// filterByStatus returns the entries with the given status, or all of them
+// for "all". The input slice is not modified.
+func filterByStatus(entries []task.IndexEntry, status string) []task.IndexEntry {
+	var filtered []task.IndexEntry
+	for _, entry := range entries {
+		if status == "all" || string(entry.Status) == status {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// Cursors are opaque to clients; they currently encode an offset
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, invalidArgf("invalid cursor")
+	}
+	return offset, nil
+}
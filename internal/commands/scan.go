@@ -0,0 +1,398 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// DefaultScanMarkers are the comment markers scan looks for by default
+var DefaultScanMarkers = []string{"TODO", "FIXME", "HACK"}
+
+// Files larger than this are not scanned
+const maxScanFileSize = 1 << 20
+
+// Directories that are never scanned, besides hidden ones
+var skipScanDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+// ScanResult is the JSON result of the scan command
+type ScanResult struct {
+	Files     int              `json:"files"`
+	Created   []ScannedComment `json:"created"`
+	Moved     []ScannedComment `json:"moved"`    // Comment found on another line
+	Resolved  []ScannedComment `json:"resolved"` // Comment removed, task marked done
+	Unchanged int              `json:"unchanged"`
+}
+
+// ScannedComment is a comment and the task it maps to
+type ScannedComment struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Marker string `json:"marker"`
+}
+
+// codeComment is a TODO-style comment found in a file
+type codeComment struct {
+	task.CodeRef
+	Text string
+}
+
+func Scan(args []string) error {
+	fs := newFlagSet("scan")
+	markersFlag := fs.String("markers", strings.Join(DefaultScanMarkers, ","), "Comma-separated comment markers")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var markers []string
+	for _, m := range strings.Split(*markersFlag, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			markers = append(markers, m)
+		}
+	}
+	if len(markers) == 0 {
+		return invalidArgf("--markers cannot be empty")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	// Paths are relative to the working directory, and stored relative to
+	// the repository root
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{s.Root()}
+	}
+	var scopes []string
+	for _, p := range paths {
+		rel, err := repoPath(s, p)
+		if err != nil {
+			return err
+		}
+		scopes = append(scopes, rel)
+	}
+
+	comments, scanned, err := scanComments(s.Root(), scopes, markers)
+	if err != nil {
+		return err
+	}
+
+	result, err := syncComments(s, scopes, scanned, comments)
+	if err != nil {
+		return err
+	}
+	result.Files = len(scanned)
+
+	if jsonOutput {
+		return writeResult("scan", result)
+	}
+
+	fmt.Printf("Scanned %d file(s): %d new, %d moved, %d resolved, %d unchanged\n",
+		result.Files, len(result.Created), len(result.Moved), len(result.Resolved), result.Unchanged)
+	printScanned("new", result.Created)
+	printScanned("moved", result.Moved)
+	printScanned("resolved", result.Resolved)
+	return nil
+}
+
+func printScanned(label string, comments []ScannedComment) {
+	for _, c := range comments {
+		fmt.Printf("  %-9s #%-4d %s:%d %s: %s\n", label, c.ID, c.File, c.Line, c.Marker, c.Title)
+	}
+}
+
+// repoPath converts a path to a slash-separated path relative to the
+// repository root ("." for the root itself)
+func repoPath(s *store.Store, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return "", notFoundf("path '%s' not found", path)
+	}
+	rel, err := filepath.Rel(s.Root(), abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", invalidArgf("path '%s' is outside the repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// inScope reports whether a repository path is one of scopes or below it
+func inScope(file string, scopes []string) bool {
+	for _, scope := range scopes {
+		if scope == "." || file == scope || strings.HasPrefix(file, scope+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// scanComments finds marker comments in the files under scopes, and returns
+// them with the set of files that were scanned. Hidden directories (.git,
+// .tasks, ...), dependency directories, binary files and large files are
+// skipped.
+func scanComments(root string, scopes []string, markers []string) ([]codeComment, map[string]bool, error) {
+	pattern := commentPattern(markers)
+
+	var comments []codeComment
+	seen := map[string]bool{}
+	scanned := map[string]bool{}
+
+	for _, scope := range scopes {
+		err := filepath.WalkDir(filepath.Join(root, filepath.FromSlash(scope)), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if d.IsDir() {
+				name := d.Name()
+				if rel != scope && (strings.HasPrefix(name, ".") || skipScanDirs[name]) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+
+			found, ok, err := scanFile(path, rel, pattern)
+			if err != nil {
+				return err
+			}
+			if ok {
+				scanned[rel] = true
+				comments = append(comments, found...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return comments, scanned, nil
+}
+
+// commentPattern matches a marker at the start of a comment, with an
+// optional "(owner)" and colon, e.g. "// TODO(ann): text" or "# FIXME text"
+func commentPattern(markers []string) *regexp.Regexp {
+	quoted := make([]string, len(markers))
+	for i, m := range markers {
+		quoted[i] = regexp.QuoteMeta(m)
+	}
+	return regexp.MustCompile(`(?:^|\s)(?://+|#+|/\*+|\*|<!--|--|;+)\s*(` + strings.Join(quoted, "|") + `)\b(?:\([^)]*\))?:?\s*(.*)`)
+}
+
+// scanFile returns the marker comments in a file, or false if the file was
+// skipped as binary or too large
+func scanFile(path, rel string, pattern *regexp.Regexp) ([]codeComment, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) > maxScanFileSize || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, false, nil
+	}
+
+	var comments []codeComment
+	occurrences := map[string]int{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxScanFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		m := pattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		marker, text := m[1], cleanCommentText(m[2])
+		key := marker + "\x00" + strings.Join(strings.Fields(text), " ")
+		occurrences[key]++
+
+		comments = append(comments, codeComment{
+			CodeRef: task.CodeRef{
+				File:        rel,
+				Line:        line,
+				Marker:      marker,
+				Fingerprint: commentFingerprint(rel, key, occurrences[key]),
+			},
+			Text: text,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", rel, err)
+	}
+
+	return comments, true, nil
+}
+
+// cleanCommentText strips comment closers from the end of a comment
+func cleanCommentText(text string) string {
+	text = strings.TrimSpace(text)
+	for _, closer := range []string{"*/", "-->"} {
+		text = strings.TrimSpace(strings.TrimSuffix(text, closer))
+	}
+	return text
+}
+
+// commentFingerprint identifies a comment by its file, marker and text, so it
+// survives lines being added or removed above it. Identical comments in one
+// file are told apart by their order.
+func commentFingerprint(file, key string, occurrence int) string {
+	sum := sha1.Sum([]byte(file + "\x00" + key + "\x00" + strconv.Itoa(occurrence)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// syncComments creates tasks for new comments, updates the line of moved
+// ones, and marks tasks done whose comment is gone from a scanned file, or
+// whose file is gone from the scanned paths. A file that was skipped (binary,
+// too large, or in a hidden or dependency directory) resolves nothing.
+func syncComments(s *store.Store, scopes []string, scanned map[string]bool, comments []codeComment) (ScanResult, error) {
+	result := ScanResult{Created: []ScannedComment{}, Moved: []ScannedComment{}, Resolved: []ScannedComment{}}
+
+	existing, err := scannedTasks(s)
+	if err != nil {
+		return result, err
+	}
+
+	found := map[string]bool{}
+	var moved, movedBefore []*task.Task
+	for _, c := range comments {
+		found[c.Fingerprint] = true
+
+		t, ok := existing[c.Fingerprint]
+		if !ok {
+			t, err := createScannedTask(s, c)
+			if err != nil {
+				return result, err
+			}
+			result.Created = append(result.Created, scannedComment(t, c.CodeRef))
+			continue
+		}
+
+		if t.Code.Line == c.Line {
+			result.Unchanged++
+			continue
+		}
+
+		// Only the location changed, which isn't worth an activity entry
+		movedBefore = append(movedBefore, t.Clone())
+		t.Code.Line = c.Line
+		t.Updated = time.Now()
+		if err := s.WriteTask(t); err != nil {
+			return result, err
+		}
+		moved = append(moved, t)
+		result.Moved = append(result.Moved, scannedComment(t, c.CodeRef))
+	}
+
+	// The index is rebuilt once for all moved comments, before anyone is told
+	if len(moved) > 0 {
+		if err := s.RebuildIndex(); err != nil {
+			return result, err
+		}
+		for i, t := range moved {
+			notifyWebhooks(s, EventTaskUpdated, movedBefore[i], t, result.Moved[i])
+		}
+	}
+
+	for _, t := range existing {
+		if found[t.Code.Fingerprint] || !inScope(t.Code.File, scopes) {
+			continue
+		}
+		if task.IsClosedStatus(t.Status) || !scannedOrGone(s, scanned, t.Code.File) {
+			continue
+		}
+
+		status := string(task.StatusDone)
+		note := fmt.Sprintf("%s comment removed from %s:%d", t.Code.Marker, t.Code.File, t.Code.Line)
+		if _, _, err := updateTask(s, t.ID, TaskChanges{Status: &status, Note: &note, System: true}); err != nil {
+			return result, err
+		}
+		result.Resolved = append(result.Resolved, scannedComment(t, *t.Code))
+	}
+
+	sort.Slice(result.Resolved, func(i, j int) bool {
+		return result.Resolved[i].ID < result.Resolved[j].ID
+	})
+	return result, nil
+}
+
+// scannedOrGone reports whether a file was scanned or no longer exists, so
+// that a comment missing from it has really been removed
+func scannedOrGone(s *store.Store, scanned map[string]bool, file string) bool {
+	if scanned[file] {
+		return true
+	}
+	_, err := os.Lstat(filepath.Join(s.Root(), filepath.FromSlash(file)))
+	return os.IsNotExist(err)
+}
+
+// createScannedTask creates a task for a comment, tagged with its marker
+func createScannedTask(s *store.Store, c codeComment) (*task.Task, error) {
+	title := c.Text
+	if title == "" {
+		title = fmt.Sprintf("%s in %s", c.Marker, c.File)
+	}
+	description := fmt.Sprintf("From a %s comment in `%s`.", c.Marker, c.File)
+
+	t, err := createTask(s, title, description)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err = s.ReadTask(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	code := c.CodeRef
+	t.Code = &code
+	if err := s.WriteTask(t); err != nil {
+		return nil, err
+	}
+
+	if _, err := tagTask(s, t.ID, strings.ToLower(c.Marker)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// scannedTasks maps comment fingerprints to the tasks created for them
+func scannedTasks(s *store.Store) (map[string]*task.Task, error) {
+	tasks := map[string]*task.Task{}
+	err := s.ForEachTask(func(t *task.Task) error {
+		if t.Code != nil {
+			tasks[t.Code.Fingerprint] = t
+		}
+		return nil
+	})
+	return tasks, err
+}
+
+func scannedComment(t *task.Task, ref task.CodeRef) ScannedComment {
+	return ScannedComment{ID: t.ID, Title: t.Title, File: ref.File, Line: ref.Line, Marker: ref.Marker}
+}
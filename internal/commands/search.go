@@ -2,36 +2,43 @@ package commands
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
-	"os"
 	"strings"
 
-	"github.com/onuse/tasks/internal/store"
 	"github.com/onuse/tasks/internal/task"
 )
 
+// SearchResult is the JSON result of the search command
+type SearchResult struct {
+	Query string      `json:"query"`
+	Tasks []task.Task `json:"tasks"`
+	Count int         `json:"count"`
+}
+
 func Search(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: task search <query> [--format FORMAT]")
+		return invalidArgf("usage: task search <query> [--format FORMAT] [--kind KIND] [--archived]")
 	}
 
 	query := strings.ToLower(args[0])
 
 	// Parse flags
-	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	fs := newFlagSet("search")
 	formatFlag := fs.String("format", "text", "Output format (text, json, compact)")
-	fs.Parse(args[1:])
+	kindFlag := fs.String("kind", "", "Only match notes of this kind (decision, question, blocker, progress)")
+	archivedFlag := fs.Bool("archived", false, "Also search archived tasks")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+	if *kindFlag != "" && !task.IsValidNoteKind(*kindFlag) {
+		return invalidArgf("invalid note kind '%s' (must be: %s)", *kindFlag, strings.Join(task.ValidNoteKinds(), ", "))
 	}
 
-	s := store.New(rootDir)
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
 
 	// Read index
 	index, err := s.ReadIndex()
@@ -39,22 +46,46 @@ func Search(args []string) error {
 		return err
 	}
 
+	ids := make([]int, len(index.Tasks))
+	for i, entry := range index.Tasks {
+		ids[i] = entry.ID
+	}
+	if *archivedFlag {
+		archived, err := s.ArchivedTaskIDs()
+		if err != nil {
+			return err
+		}
+		ids = append(ids, archived...)
+	}
+
 	// Search through all tasks
 	var matches []task.Task
-	for _, entry := range index.Tasks {
+	for _, id := range ids {
 		// Read full task for searching
-		t, err := s.ReadTask(entry.ID)
+		t, err := s.ReadTask(id)
 		if err != nil {
 			continue // Skip tasks we can't read
 		}
 
-		// Check if query matches title, description, notes, or tags
-		if matchesQuery(t, query) {
+		// With --kind, only notes of that kind are searched; otherwise check
+		// title, description, notes, and tags
+		if *kindFlag != "" {
+			if matchesNoteKind(t, *kindFlag, query) {
+				matches = append(matches, *t)
+			}
+		} else if matchesQuery(t, query) {
 			matches = append(matches, *t)
 		}
 	}
 
 	// Output results
+	if jsonOutput {
+		if matches == nil {
+			matches = []task.Task{}
+		}
+		return writeResult("search", SearchResult{Query: args[0], Tasks: matches, Count: len(matches)})
+	}
+
 	switch *formatFlag {
 	case "json":
 		return outputSearchJSON(matches)
@@ -93,6 +124,17 @@ func matchesQuery(t *task.Task, query string) bool {
 	return false
 }
 
+// matchesNoteKind reports whether the task has a note of the given kind
+// containing query (an empty query matches any such note)
+func matchesNoteKind(t *task.Task, kind string, query string) bool {
+	for _, note := range t.NotesOfKind(kind) {
+		if strings.Contains(strings.ToLower(note.Text), query) {
+			return true
+		}
+	}
+	return false
+}
+
 func outputSearchText(tasks []task.Task) error {
 	if len(tasks) == 0 {
 		fmt.Println("No tasks found")
@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// DefaultAgent is the identity used when neither --agent nor TASK_AGENT is set
+const DefaultAgent = "human"
+
+// agentFlag is set by the global --agent flag
+var agentFlag string
+
+// SetAgent sets the agent identity applied to all writes
+func SetAgent(name string) {
+	agentFlag = name
+}
+
+// currentAgent returns the identity of whoever is running the command:
+// --agent, then $TASK_AGENT, then DefaultAgent
+func currentAgent() string {
+	if agentFlag != "" {
+		return agentFlag
+	}
+	if env := strings.TrimSpace(os.Getenv("TASK_AGENT")); env != "" {
+		return env
+	}
+	return DefaultAgent
+}
+
+// currentSessionID returns the ID of the current agent's open session, or ""
+func currentSessionID(s *store.Store) string {
+	session, err := s.CurrentSession(currentAgent())
+	if err != nil || session == nil {
+		return ""
+	}
+	return session.ID
+}
+
+// recordActivity appends events to the current agent's open session. It does
+// nothing when no session is open.
+func recordActivity(s *store.Store, events ...task.SessionEvent) error {
+	session, err := s.CurrentSession(currentAgent())
+	if err != nil || session == nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, event := range events {
+		if event.Timestamp.IsZero() {
+			event.Timestamp = now
+		}
+		session.AddEvent(event)
+	}
+
+	return s.WriteSession(session)
+}
+
+// SessionResult is the JSON result of the session subcommands
+type SessionResult struct {
+	Session *task.Session `json:"session"`
+}
+
+// SessionsResult is the JSON result of the sessions command
+type SessionsResult struct {
+	Sessions []task.Session `json:"sessions"`
+	Count    int            `json:"count"`
+}
+
+func Session(args []string) error {
+	if len(args) < 1 {
+		return invalidArgf("usage: task session <start|end|show> [options]")
+	}
+
+	switch args[0] {
+	case "start":
+		return sessionStart(args[1:])
+	case "end":
+		return sessionEnd(args[1:])
+	case "show":
+		return sessionShow(args[1:])
+	default:
+		return invalidArgf("unknown session subcommand '%s' (must be: start, end, show)", args[0])
+	}
+}
+
+func sessionStart(args []string) error {
+	fs := newFlagSet("session start")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	agent := currentAgent()
+
+	current, err := s.CurrentSession(agent)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		return newError(ErrCodeConflict, "agent '%s' already has an open session %s (end it with 'task session end')", agent, current.ID)
+	}
+
+	now := time.Now()
+	session := &task.Session{
+		ID:      store.NewSessionID(agent, now),
+		Agent:   agent,
+		Started: now,
+		Tasks:   []int{},
+		Events:  []task.SessionEvent{},
+	}
+
+	if err := s.CreateSession(session); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("session", SessionResult{Session: session})
+	}
+
+	fmt.Printf("Started session %s (agent %s)\n", session.ID, agent)
+	return nil
+}
+
+func sessionEnd(args []string) error {
+	fs := newFlagSet("session end")
+	summaryFlag := fs.String("summary", "", "Summary of what was done in the session")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	agent := currentAgent()
+
+	session, err := s.CurrentSession(agent)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return notFoundf("agent '%s' has no open session", agent)
+	}
+
+	now := time.Now()
+	session.Ended = &now
+	session.Summary = *summaryFlag
+
+	if err := s.WriteSession(session); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("session", SessionResult{Session: session})
+	}
+
+	fmt.Printf("Ended session %s: %d task(s) touched, %d change(s)\n", session.ID, len(session.Tasks), len(session.Events))
+	return nil
+}
+
+func sessionShow(args []string) error {
+	fs := newFlagSet("session show")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return invalidArgf("usage: task session show [id]")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	var session *task.Session
+	if fs.NArg() > 0 {
+		session, err = s.ReadSession(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+	} else {
+		session, err = s.CurrentSession(currentAgent())
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return notFoundf("agent '%s' has no open session", currentAgent())
+		}
+	}
+
+	if jsonOutput {
+		return writeResult("session", SessionResult{Session: session})
+	}
+
+	fmt.Printf("Session %s\n", session.ID)
+	fmt.Printf("Agent: %s\n", session.Agent)
+	fmt.Printf("Started: %s\n", session.Started.Format("2006-01-02 15:04:05"))
+	if session.Ended != nil {
+		fmt.Printf("Ended: %s\n", session.Ended.Format("2006-01-02 15:04:05"))
+	} else {
+		fmt.Println("Ended: (open)")
+	}
+	fmt.Println()
+
+	if session.Summary != "" {
+		fmt.Println("Summary:")
+		fmt.Println(session.Summary)
+		fmt.Println()
+	}
+
+	if len(session.Tasks) > 0 {
+		ids := make([]string, len(session.Tasks))
+		for i, id := range session.Tasks {
+			ids[i] = fmt.Sprintf("#%d", id)
+		}
+		fmt.Printf("Tasks: %s\n", strings.Join(ids, ", "))
+		fmt.Println()
+	}
+
+	if len(session.Events) > 0 {
+		fmt.Println("Changes:")
+		for _, event := range session.Events {
+			fmt.Printf("  [%s] #%d %s\n", event.Timestamp.Format("2006-01-02 15:04"), event.TaskID, describeEvent(event))
+		}
+	}
+
+	return nil
+}
+
+func Sessions(args []string) error {
+	fs := newFlagSet("sessions")
+	agentFilter := fs.String("agent", "", "Only show sessions of this agent")
+	taskFilter := fs.Int("task", 0, "Only show sessions that touched this task")
+	limitFlag := fs.Int("limit", 0, "Show at most N most recent sessions (0 = all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	all, err := s.ListSessions()
+	if err != nil {
+		return err
+	}
+
+	sessions := []task.Session{}
+	for _, session := range all {
+		if *agentFilter != "" && session.Agent != *agentFilter {
+			continue
+		}
+		if *taskFilter != 0 && !session.Touched(*taskFilter) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	if *limitFlag > 0 && len(sessions) > *limitFlag {
+		sessions = sessions[len(sessions)-*limitFlag:]
+	}
+
+	if jsonOutput {
+		return writeResult("sessions", SessionsResult{Sessions: sessions, Count: len(sessions)})
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found")
+		return nil
+	}
+
+	for _, session := range sessions {
+		fmt.Println(formatSessionLine(session))
+	}
+	return nil
+}
+
+// formatSessionLine returns a one-line summary of a session
+func formatSessionLine(session task.Session) string {
+	ended := "open"
+	if session.Ended != nil {
+		ended = session.Ended.Format("15:04")
+	}
+
+	line := fmt.Sprintf("%s  %-10s %s-%s  %d task(s)",
+		session.ID, session.Agent, session.Started.Format("2006-01-02 15:04"), ended, len(session.Tasks))
+	if session.Summary != "" {
+		line += "  " + session.Summary
+	}
+	return line
+}
+
+// describeEvent returns a short human-readable description of a session event
+func describeEvent(event task.SessionEvent) string {
+	switch event.Action {
+	case task.ActionCreated:
+		return fmt.Sprintf("created: %s", event.To)
+	case task.ActionStatus:
+		return fmt.Sprintf("status %s -> %s", event.From, event.To)
+	case task.ActionNote:
+		return fmt.Sprintf("note: %s", event.To)
+	case task.ActionMerge:
+		if event.From != "" {
+			return fmt.Sprintf("merged #%s into this task", event.From)
+		}
+		return fmt.Sprintf("merged into #%s", event.To)
+	default:
+		if event.To != "" {
+			return fmt.Sprintf("%s: %s", event.Action, event.To)
+		}
+		if event.From != "" {
+			return fmt.Sprintf("%s: %s", event.Action, event.From)
+		}
+		return event.Action
+	}
+}
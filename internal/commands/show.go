@@ -3,31 +3,46 @@ package commands
 import (
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 
-	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/markdown"
+	"github.com/onuse/tasks/internal/task"
 )
 
+// ShowResult is the JSON result of the show command
+type ShowResult struct {
+	Task     *task.Task     `json:"task"`
+	Archived bool           `json:"archived"`
+	Sessions []task.Session `json:"sessions"`
+}
+
 func Show(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: task show <id>")
+		return invalidArgf("usage: task show <id> [--render]")
 	}
 
-	id, err := strconv.Atoi(args[0])
+	id, err := parseTaskID(args[0], "task ID")
 	if err != nil {
-		return fmt.Errorf("invalid task ID '%s'", args[0])
+		return err
 	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+	fs := newFlagSet("show")
+	renderFlag := fs.Bool("render", false, "Render Markdown in the description and notes")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
 	}
 
-	s := store.New(rootDir)
+	// Text is printed as stored unless rendering was asked for
+	render := func(text string) string { return text }
+	if *renderFlag {
+		color := useColor(os.Stdout)
+		render = func(text string) string { return markdown.ToTerminal(text, color) }
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
 
 	// Read task
 	t, err := s.ReadTask(id)
@@ -35,16 +50,54 @@ func Show(args []string) error {
 		return err
 	}
 
+	archived := s.IsArchived(id)
+
+	// Find agent sessions that touched the task
+	allSessions, err := s.ListSessions()
+	if err != nil {
+		return err
+	}
+	sessions := []task.Session{}
+	for _, session := range allSessions {
+		if session.Touched(id) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	if jsonOutput {
+		return writeResult("show", ShowResult{Task: t, Archived: archived, Sessions: sessions})
+	}
+
 	// Display task
 	fmt.Printf("Task #%d: %s\n", t.ID, t.Title)
-	fmt.Printf("Status: %s\n", t.Status)
+	if archived {
+		fmt.Printf("Status: %s (archived)\n", t.Status)
+	} else {
+		fmt.Printf("Status: %s\n", t.Status)
+	}
 	fmt.Printf("Created: %s\n", t.Created.Format("2006-01-02 15:04:05"))
 	fmt.Printf("Updated: %s\n", t.Updated.Format("2006-01-02 15:04:05"))
+	if t.Due != "" {
+		fmt.Printf("Due: %s\n", t.Due)
+	}
+	if t.Completed != nil {
+		fmt.Printf("Completed: %s\n", t.Completed.Format("2006-01-02 15:04:05"))
+	}
+	if t.External != nil {
+		fmt.Printf("External: %s %s", t.External.Source, t.External.ID)
+		if t.External.URL != "" {
+			fmt.Printf(" (%s)", t.External.URL)
+		}
+		fmt.Println()
+	}
+	if t.Code != nil {
+		fmt.Printf("Code: %s:%d (%s)\n", t.Code.File, t.Code.Line, t.Code.Marker)
+	}
 	fmt.Println()
 
 	if t.Description != "" {
 		fmt.Println("Description:")
-		fmt.Println(t.Description)
+		fmt.Println(render(t.Description))
 		fmt.Println()
 	}
 
@@ -78,12 +131,44 @@ func Show(args []string) error {
 	if len(t.Notes) > 0 {
 		fmt.Println("Notes:")
 		for _, note := range t.Notes {
+			author := note.Author
+			if note.Kind != "" {
+				author = fmt.Sprintf("%s (%s)", note.Author, note.Kind)
+			}
+			if *renderFlag {
+				// Rendered notes may span several lines; indent them under the header
+				fmt.Printf("  [%s] %s:\n", note.Timestamp.Format("2006-01-02 15:04"), author)
+				for _, line := range strings.Split(render(note.Text), "\n") {
+					fmt.Println(strings.TrimRight("    "+line, " "))
+				}
+				continue
+			}
 			fmt.Printf("  [%s] %s: %s\n",
 				note.Timestamp.Format("2006-01-02 15:04"),
-				note.Author,
+				author,
 				note.Text)
 		}
 	}
 
+	if len(sessions) > 0 {
+		if len(t.Notes) > 0 {
+			fmt.Println()
+		}
+		fmt.Println("Sessions:")
+		for _, session := range sessions {
+			fmt.Printf("  %s\n", formatSessionLine(session))
+		}
+	}
+
 	return nil
 }
+
+// useColor reports whether ANSI colors should be written to f: it must be a
+// terminal, and NO_COLOR (https://no-color.org) must not be set
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,10 +1,8 @@
 package commands
 
 import (
-	"flag"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -12,125 +10,185 @@ import (
 	"github.com/onuse/tasks/internal/task"
 )
 
+// TagResult is the JSON result of the tag and untag commands
+type TagResult struct {
+	ID      int    `json:"id"`
+	Tag     string `json:"tag"`
+	LabelID int    `json:"label_id"`
+	Changed bool   `json:"changed"`
+}
+
 func Tag(args []string) error {
-	fs := flag.NewFlagSet("tag", flag.ExitOnError)
-	fs.Parse(args)
+	fs := newFlagSet("tag")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
 
 	if fs.NArg() < 2 {
-		return fmt.Errorf("usage: task tag <id> <tag_name>")
+		return invalidArgf("usage: task tag <id> <tag_name>")
 	}
 
 	// Parse task ID
-	taskID, err := strconv.Atoi(fs.Arg(0))
+	taskID, err := parseTaskID(fs.Arg(0), "task ID")
 	if err != nil {
-		return fmt.Errorf("invalid task ID '%s'", fs.Arg(0))
+		return err
 	}
 
 	tagName := fs.Arg(1)
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	s, err := openStore()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
 	}
 
-	s := store.New(rootDir)
-
-	// Read the task
-	t, err := s.ReadTask(taskID)
+	result, err := tagTask(s, taskID, tagName)
 	if err != nil {
-		return fmt.Errorf("task #%d not found", taskID)
+		return err
 	}
 
-	// Find or create label task
-	labelTask, err := findOrCreateLabel(s, tagName)
-	if err != nil {
-		return err
+	if jsonOutput {
+		return writeResult("tag", result)
 	}
 
-	// Check if already tagged
-	if t.HasLink(labelTask.ID, task.LinkTypeChild) {
+	if !result.Changed {
 		fmt.Printf("Task #%d already tagged with '%s'\n", taskID, tagName)
 		return nil
 	}
 
-	// Add link from task to label (task is child of label)
-	t.AddLink(labelTask.ID, task.LinkTypeChild, "")
-	t.Updated = time.Now()
+	fmt.Printf("Tagged task #%d with '%s' (label task #%d)\n", taskID, tagName, result.LabelID)
+	return nil
+}
 
-	// Save task
-	if err := s.WriteTask(t); err != nil {
+func Untag(args []string) error {
+	fs := newFlagSet("untag")
+	if err := parseFlags(fs, args); err != nil {
 		return err
 	}
 
-	// Rebuild index
-	if err := s.RebuildIndex(); err != nil {
+	if fs.NArg() < 2 {
+		return invalidArgf("usage: task untag <id> <tag_name>")
+	}
+
+	// Parse task ID
+	taskID, err := parseTaskID(fs.Arg(0), "task ID")
+	if err != nil {
+		return err
+	}
+
+	tagName := fs.Arg(1)
+
+	s, err := openStore()
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Tagged task #%d with '%s' (label task #%d)\n", taskID, tagName, labelTask.ID)
+	result, err := untagTask(s, taskID, tagName)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("untag", result)
+	}
+
+	fmt.Printf("Removed tag '%s' from task #%d\n", tagName, taskID)
 	return nil
 }
 
-func Untag(args []string) error {
-	fs := flag.NewFlagSet("untag", flag.ExitOnError)
-	fs.Parse(args)
+// tagTask tags a task with a label, creating the label if needed. Tagging an
+// already tagged task is not an error; Changed is false in that case. It is
+// shared by the CLI and the web API.
+func tagTask(s *store.Store, taskID int, tagName string) (TagResult, error) {
+	if strings.TrimSpace(tagName) == "" {
+		return TagResult{}, invalidArgf("tag name cannot be empty")
+	}
 
-	if fs.NArg() < 2 {
-		return fmt.Errorf("usage: task untag <id> <tag_name>")
+	// Read the task
+	t, err := s.ReadTask(taskID)
+	if errors.Is(err, store.ErrNotFound) {
+		return TagResult{}, notFoundf("task #%d not found", taskID)
+	} else if err != nil {
+		return TagResult{}, err
 	}
 
-	// Parse task ID
-	taskID, err := strconv.Atoi(fs.Arg(0))
+	// Find or create label task
+	labelTask, err := findOrCreateLabel(s, tagName)
 	if err != nil {
-		return fmt.Errorf("invalid task ID '%s'", fs.Arg(0))
+		return TagResult{}, err
 	}
 
-	tagName := fs.Arg(1)
+	result := TagResult{ID: taskID, Tag: tagName, LabelID: labelTask.ID}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+	// Check if already tagged
+	if t.HasLink(labelTask.ID, task.LinkTypeChild) {
+		return result, nil
 	}
 
-	s := store.New(rootDir)
+	// Add link from task to label (task is child of label)
+	before := t.Clone()
+	t.AddLink(labelTask.ID, task.LinkTypeChild, "")
+	t.Updated = time.Now()
 
+	// Save task
+	if err := s.WriteTask(t); err != nil {
+		return TagResult{}, err
+	}
+
+	// Rebuild index
+	if err := s.RebuildIndex(); err != nil {
+		return TagResult{}, err
+	}
+
+	if err := recordActivity(s, task.SessionEvent{TaskID: taskID, Action: task.ActionTag, To: tagName}); err != nil {
+		return TagResult{}, err
+	}
+
+	result.Changed = true
+	notifyWebhooks(s, EventTaskTagged, before, t, result)
+	return result, nil
+}
+
+// untagTask removes a label from a task. It is shared by the CLI and the web API.
+func untagTask(s *store.Store, taskID int, tagName string) (TagResult, error) {
 	// Read the task
 	t, err := s.ReadTask(taskID)
-	if err != nil {
-		return fmt.Errorf("task #%d not found", taskID)
+	if errors.Is(err, store.ErrNotFound) {
+		return TagResult{}, notFoundf("task #%d not found", taskID)
+	} else if err != nil {
+		return TagResult{}, err
 	}
 
 	// Find label task
 	labelTask, err := findLabelByName(s, tagName)
 	if err != nil {
-		return fmt.Errorf("label '%s' not found", tagName)
+		return TagResult{}, notFoundf("label '%s' not found", tagName)
 	}
 
 	// Remove link
+	before := t.Clone()
 	if !t.RemoveLink(labelTask.ID, task.LinkTypeChild) {
-		return fmt.Errorf("task #%d is not tagged with '%s'", taskID, tagName)
+		return TagResult{}, notFoundf("task #%d is not tagged with '%s'", taskID, tagName)
 	}
 
 	t.Updated = time.Now()
 
 	// Save task
 	if err := s.WriteTask(t); err != nil {
-		return err
+		return TagResult{}, err
 	}
 
 	// Rebuild index
 	if err := s.RebuildIndex(); err != nil {
-		return err
+		return TagResult{}, err
 	}
 
-	fmt.Printf("Removed tag '%s' from task #%d\n", tagName, taskID)
-	return nil
+	if err := recordActivity(s, task.SessionEvent{TaskID: taskID, Action: task.ActionUntag, From: tagName}); err != nil {
+		return TagResult{}, err
+	}
+
+	result := TagResult{ID: taskID, Tag: tagName, LabelID: labelTask.ID, Changed: true}
+	notifyWebhooks(s, EventTaskUntagged, before, t, result)
+	return result, nil
 }
 
 // findOrCreateLabel finds an existing label task by name (case-insensitive) or creates a new one
@@ -173,6 +231,8 @@ func findOrCreateLabel(s *store.Store, name string) (*task.Task, error) {
 		return nil, err
 	}
 
+	notifyWebhooks(s, EventTaskCreated, nil, newTask, nil)
+
 	return newTask, nil
 }
 
@@ -192,5 +252,5 @@ func findLabelByName(s *store.Store, name string) (*task.Task, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("label '%s' not found", name)
+	return nil, notFoundf("label '%s' not found", name)
 }
@@ -1,105 +1,144 @@
 package commands
 
 import (
-	"flag"
 	"fmt"
-	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
 )
 
+// UnlinkResult is the JSON result of the unlink command
+type UnlinkResult struct {
+	Removed []LinkChange `json:"removed"`
+}
+
 func Unlink(args []string) error {
 	if len(args) < 2 {
-		return fmt.Errorf("usage: task unlink <id> <target_id> [--type TYPE] [--bidirectional]")
+		return invalidArgf("usage: task unlink <id> <target_id> [--type TYPE] [--bidirectional]")
 	}
 
 	// Parse source and target IDs
-	sourceID, err := strconv.Atoi(args[0])
+	sourceID, err := parseTaskID(args[0], "source task ID")
 	if err != nil {
-		return fmt.Errorf("invalid source task ID '%s'", args[0])
+		return err
 	}
 
-	targetID, err := strconv.Atoi(args[1])
+	targetID, err := parseTaskID(args[1], "target task ID")
 	if err != nil {
-		return fmt.Errorf("invalid target task ID '%s'", args[1])
+		return err
 	}
 
 	// Parse flags
-	fs := flag.NewFlagSet("unlink", flag.ExitOnError)
+	fs := newFlagSet("unlink")
 	linkType := fs.String("type", "", "Link type to remove (if empty, removes all links to target)")
 	bidirectional := fs.Bool("bidirectional", false, "Remove reciprocal link as well")
-	fs.Parse(args[2:])
+	if err := parseFlags(fs, args[2:]); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	removed, err := unlinkTasks(s, sourceID, targetID, *linkType, *bidirectional)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("unlink", UnlinkResult{Removed: removed})
 	}
 
-	s := store.New(rootDir)
+	if *linkType != "" {
+		fmt.Printf("Removed link (%s) from task #%d to #%d\n", *linkType, sourceID, targetID)
+	} else {
+		fmt.Printf("Removed all links from task #%d to #%d\n", sourceID, targetID)
+	}
 
+	if len(removed) > 1 {
+		if removed[1].Type != "" {
+			fmt.Printf("Removed reciprocal link (%s) from task #%d to #%d\n", removed[1].Type, targetID, sourceID)
+		} else {
+			fmt.Printf("Removed all reciprocal links from task #%d to #%d\n", targetID, sourceID)
+		}
+	}
+
+	return nil
+}
+
+// unlinkTasks removes links from source to target (all of them when linkType
+// is empty), and the reciprocal links when bidirectional is set. It is shared
+// by the CLI and the web API.
+func unlinkTasks(s *store.Store, sourceID, targetID int, linkType string, bidirectional bool) ([]LinkChange, error) {
 	// Read source task
 	sourceTask, err := s.ReadTask(sourceID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	sourceBefore := sourceTask.Clone()
 
 	// Remove link
-	if !sourceTask.RemoveLink(targetID, *linkType) {
-		return fmt.Errorf("no link found from task #%d to #%d", sourceID, targetID)
+	if !sourceTask.RemoveLink(targetID, linkType) {
+		return nil, notFoundf("no link found from task #%d to #%d", sourceID, targetID)
 	}
 
 	sourceTask.Updated = time.Now()
 
 	if err := s.WriteTask(sourceTask); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Rebuild index
 	if err := s.RebuildIndex(); err != nil {
-		return err
+		return nil, err
 	}
 
-	if *linkType != "" {
-		fmt.Printf("Removed link (%s) from task #%d to #%d\n", *linkType, sourceID, targetID)
-	} else {
-		fmt.Printf("Removed all links from task #%d to #%d\n", sourceID, targetID)
-	}
+	removed := []LinkChange{{SourceID: sourceID, TargetID: targetID, Type: linkType}}
 
 	// Handle bidirectional unlinking
-	if *bidirectional {
-		targetTask, err := s.ReadTask(targetID)
+	var targetBefore, targetTask *task.Task
+	if bidirectional {
+		targetTask, err = s.ReadTask(targetID)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		targetBefore = targetTask.Clone()
 
 		reciprocalType := ""
-		if *linkType != "" {
-			reciprocalType = getReciprocalLinkType(*linkType)
+		if linkType != "" {
+			reciprocalType = getReciprocalLinkType(linkType)
 		}
 
 		if targetTask.RemoveLink(sourceID, reciprocalType) {
 			targetTask.Updated = time.Now()
 
 			if err := s.WriteTask(targetTask); err != nil {
-				return err
+				return nil, err
 			}
 
 			if err := s.RebuildIndex(); err != nil {
-				return err
+				return nil, err
 			}
 
-			if reciprocalType != "" {
-				fmt.Printf("Removed reciprocal link (%s) from task #%d to #%d\n", reciprocalType, targetID, sourceID)
-			} else {
-				fmt.Printf("Removed all reciprocal links from task #%d to #%d\n", targetID, sourceID)
-			}
+			removed = append(removed, LinkChange{SourceID: targetID, TargetID: sourceID, Type: reciprocalType})
 		}
 	}
 
-	return nil
+	events := make([]task.SessionEvent, len(removed))
+	for i, link := range removed {
+		events[i] = task.SessionEvent{TaskID: link.SourceID, Action: task.ActionUnlink, From: strings.TrimSpace(fmt.Sprintf("%s #%d", link.Type, link.TargetID))}
+	}
+	if err := recordActivity(s, events...); err != nil {
+		return nil, err
+	}
+
+	notifyWebhooks(s, EventTaskUnlinked, sourceBefore, sourceTask, removed[0])
+	if len(removed) > 1 {
+		notifyWebhooks(s, EventTaskUnlinked, targetBefore, targetTask, removed[1])
+	}
+
+	return removed, nil
 }
@@ -1,86 +1,249 @@
 package commands
 
 import (
-	"flag"
 	"fmt"
-	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/onuse/tasks/internal/store"
 	"github.com/onuse/tasks/internal/task"
 )
 
+// UpdateResult is the JSON result of the update command
+type UpdateResult struct {
+	ID      int           `json:"id"`
+	Changes []FieldChange `json:"changes"`
+	Task    *task.Task    `json:"task"`
+}
+
+// TaskChanges describes an update to a task. Nil fields are left unchanged.
+type TaskChanges struct {
+	Status      *string `json:"status,omitempty"`
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Due         *string `json:"due,omitempty"` // YYYY-MM-DD, or "" to clear
+	Note        *string `json:"note,omitempty"`
+	NoteKind    string  `json:"note_kind,omitempty"`
+	Author      string  `json:"author,omitempty"` // Note author; defaults to the current agent
+	Force       bool    `json:"force,omitempty"`  // Exceed WIP limits, noting it on the task
+
+	// System marks a status change made to mirror something outside the
+	// workflow (see checkTransition). Never set from API requests.
+	System bool `json:"-"`
+}
+
 func Update(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: task update <id> [--status STATUS] [--note NOTE] [--title TITLE] [--description DESC]")
+		return invalidArgf("usage: task update <id> [--status STATUS [--force]] [--note NOTE] [--note-kind KIND] [--title TITLE] [--description DESC] [--due DATE]")
 	}
 
-	id, err := strconv.Atoi(args[0])
+	id, err := parseTaskID(args[0], "task ID")
 	if err != nil {
-		return fmt.Errorf("invalid task ID '%s'", args[0])
+		return err
 	}
 
 	// Parse flags
-	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	fs := newFlagSet("update")
 	statusFlag := fs.String("status", "", "New status")
 	noteFlag := fs.String("note", "", "Add a note")
+	noteKindFlag := fs.String("note-kind", "", "Kind of the added note (decision, question, blocker, progress)")
 	titleFlag := fs.String("title", "", "New title")
 	descFlag := fs.String("description", "", "New description")
-	authorFlag := fs.String("author", "human", "Note author")
-	fs.Parse(args[1:])
+	dueFlag := fs.String("due", "", "Due date (YYYY-MM-DD, or 'none' to clear)")
+	authorFlag := fs.String("author", currentAgent(), "Note author (defaults to --agent or $TASK_AGENT)")
+	forceFlag := fs.Bool("force", false, "Move the task even if the status is at its WIP limit")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	// Empty flags mean "not given" on the command line
+	changes := TaskChanges{NoteKind: *noteKindFlag, Author: *authorFlag, Force: *forceFlag}
+	if *statusFlag != "" {
+		changes.Status = statusFlag
+	}
+	if *titleFlag != "" {
+		changes.Title = titleFlag
+	}
+	if *descFlag != "" {
+		changes.Description = descFlag
+	}
+	if *noteFlag != "" {
+		changes.Note = noteFlag
+	}
+	if *dueFlag != "" {
+		due := *dueFlag
+		if due == "none" {
+			due = ""
+		}
+		changes.Due = &due
+	}
 
-	// Validate status if provided
-	if *statusFlag != "" && !task.IsValidStatus(*statusFlag) {
-		return fmt.Errorf("invalid status '%s' (must be: backlog, active, done, cancelled)", *statusFlag)
+	s, err := openStore()
+	if err != nil {
+		return err
 	}
 
-	// Find task root
-	rootDir, err := store.FindTaskRoot()
+	t, fieldChanges, err := updateTask(s, id, changes)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'task init' to initialize task tracking in this repository.\n")
-		os.Exit(3)
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("update", UpdateResult{ID: id, Changes: fieldChanges, Task: t})
+	}
+
+	fmt.Printf("Updated task #%d\n", id)
+	return nil
+}
+
+// validateTaskChanges checks a TaskChanges value without touching the store
+func validateTaskChanges(c TaskChanges) error {
+	if c.Status != nil && !task.IsValidStatus(*c.Status) {
+		return invalidArgf("invalid status '%s' (must be: %s)", *c.Status, statusNames())
+	}
+
+	if c.Title != nil && *c.Title == "" {
+		return invalidArgf("title cannot be empty")
+	}
+
+	if c.NoteKind != "" {
+		if c.Note == nil {
+			return invalidArgf("a note kind requires a note")
+		}
+		if !task.IsValidNoteKind(c.NoteKind) {
+			return invalidArgf("invalid note kind '%s' (must be: %s)", c.NoteKind, strings.Join(task.ValidNoteKinds(), ", "))
+		}
+	}
+
+	if c.Note != nil && *c.Note == "" {
+		return invalidArgf("note cannot be empty")
 	}
 
-	s := store.New(rootDir)
+	if c.Due != nil && *c.Due != "" && !task.IsValidDueDate(*c.Due) {
+		return invalidArgf("invalid due date '%s' (must be YYYY-MM-DD)", *c.Due)
+	}
+
+	if c.Status == nil && c.Title == nil && c.Description == nil && c.Due == nil && c.Note == nil {
+		return newError(ErrCodeNoChanges, "no updates specified")
+	}
+
+	return nil
+}
+
+// checkTransition reports a status change the workflow doesn't allow.
+// System transitions are exempt: scan, the post-commit hook and import close
+// or reopen tasks because a comment, commit or issue says so, and refusing
+// would leave the task out of step with what it mirrors. They are also
+// exempt from WIP limits, with a note when one is exceeded.
+func checkTransition(t *task.Task, to task.Status, system bool) error {
+	workflow := task.CurrentWorkflow()
+	if system || workflow.CanTransition(t.Status, to) {
+		return nil
+	}
+
+	allowed, _ := workflow.AllowedTransitions(t.Status)
+	names := make([]string, len(allowed))
+	for i, status := range allowed {
+		names[i] = string(status)
+	}
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+	return newError(ErrCodeConflict, "task #%d can't move from %s to %s (allowed: %s)", t.ID, t.Status, to, strings.Join(names, ", "))
+}
+
+// updateTask applies changes to a task and returns the updated task and the
+// list of changed fields. It is shared by the CLI and the web API.
+func updateTask(s *store.Store, id int, c TaskChanges) (*task.Task, []FieldChange, error) {
+	if err := validateTaskChanges(c); err != nil {
+		return nil, nil, err
+	}
 
 	// Read task
 	t, err := s.ReadTask(id)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	before := t.Clone()
+
+	author := c.Author
+	if author == "" {
+		author = currentAgent()
 	}
 
 	// Apply updates
-	updated := false
+	var changes []FieldChange
+	var events []task.SessionEvent
+	var notes []task.Note
 
-	if *statusFlag != "" {
-		t.Status = task.Status(*statusFlag)
-		updated = true
+	if c.Status != nil {
+		// An archived task is left out of the index, so a reopened one would
+		// vanish from list, the board and WIP counts
+		if t.Status != task.Status(*c.Status) && s.IsArchived(id) {
+			cmdErr := newError(ErrCodeConflict, "task #%d is archived; unarchive it before changing its status", id)
+			cmdErr.Hint = fmt.Sprintf("Run 'task unarchive %d' first.", id)
+			return nil, nil, cmdErr
+		}
+		if err := checkTransition(t, task.Status(*c.Status), c.System); err != nil {
+			return nil, nil, err
+		}
+		if t.Status != task.Status(*c.Status) {
+			exceeded, err := checkWIPLimit(s, task.Status(*c.Status), author, c.Force || c.System)
+			if err != nil {
+				return nil, nil, err
+			}
+			if exceeded != "" {
+				notes = append(notes, task.Note{Timestamp: time.Now(), Author: author, Text: "Exceeded WIP limit: " + exceeded})
+			}
+			now := time.Now()
+			t.StatusBy = author
+			t.StatusSince = &now
+		}
+		changes = append(changes, FieldChange{Field: "status", From: t.Status, To: task.Status(*c.Status)})
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionStatus, From: string(t.Status), To: *c.Status})
+		t.Status = task.Status(*c.Status)
+
+		// Record when the task was closed, and forget it if it's reopened
+		if task.IsClosedStatus(t.Status) && !task.IsClosedStatus(before.Status) {
+			now := time.Now()
+			t.Completed = &now
+		} else if !task.IsClosedStatus(t.Status) {
+			t.Completed = nil
+		}
 	}
 
-	if *titleFlag != "" {
-		t.Title = *titleFlag
-		updated = true
+	if c.Title != nil {
+		changes = append(changes, FieldChange{Field: "title", From: t.Title, To: *c.Title})
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionTitle, From: t.Title, To: *c.Title})
+		t.Title = *c.Title
 	}
 
-	if *descFlag != "" {
-		t.Description = *descFlag
-		updated = true
+	if c.Description != nil {
+		changes = append(changes, FieldChange{Field: "description", From: t.Description, To: *c.Description})
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionDescription})
+		t.Description = *c.Description
 	}
 
-	if *noteFlag != "" {
-		note := task.Note{
+	if c.Due != nil {
+		changes = append(changes, FieldChange{Field: "due", From: t.Due, To: *c.Due})
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionDue, From: t.Due, To: *c.Due})
+		t.Due = *c.Due
+	}
+
+	if c.Note != nil {
+		notes = append(notes, task.Note{
 			Timestamp: time.Now(),
-			Author:    *authorFlag,
-			Text:      *noteFlag,
-		}
-		t.Notes = append(t.Notes, note)
-		updated = true
+			Author:    author,
+			Text:      *c.Note,
+			Kind:      c.NoteKind,
+		})
 	}
 
-	if !updated {
-		return fmt.Errorf("no updates specified")
+	for _, note := range notes {
+		note.Session = currentSessionID(s)
+		t.Notes = append(t.Notes, note)
+		changes = append(changes, FieldChange{Field: "notes", To: note})
+		events = append(events, task.SessionEvent{TaskID: id, Action: task.ActionNote, To: note.Text})
 	}
 
 	// Update timestamp
@@ -88,14 +251,19 @@ func Update(args []string) error {
 
 	// Write task
 	if err := s.WriteTask(t); err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Rebuild index
 	if err := s.RebuildIndex(); err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	fmt.Printf("Updated task #%d\n", id)
-	return nil
+	if err := recordActivity(s, events...); err != nil {
+		return nil, nil, err
+	}
+
+	notifyWebhooks(s, EventTaskUpdated, before, t, changes)
+
+	return t, changes, nil
 }
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// newTestStore returns an initialized store with one backlog task, #1
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s := store.New(t.TempDir())
+	if err := s.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if _, err := createTask(s, "Test task", ""); err != nil {
+		t.Fatalf("createTask: %v", err)
+	}
+	return s
+}
+
+func TestUpdateTaskCompleted(t *testing.T) {
+	workflow := task.DefaultWorkflow()
+	workflow.Statuses = append(workflow.Statuses, task.WorkflowStatus{Name: "wontfix", Category: task.CategoryClosed})
+	task.SetWorkflow(workflow)
+	t.Cleanup(func() { task.SetWorkflow(nil) })
+
+	tests := []struct {
+		name          string
+		statuses      []string
+		wantCompleted bool
+	}{
+		{"done", []string{"done"}, true},
+		{"cancelled", []string{"cancelled"}, true},
+		{"custom closed status", []string{"wontfix"}, true},
+		{"open status", []string{"active"}, false},
+		{"reopened", []string{"done", "active"}, false},
+		{"closed twice keeps the first time", []string{"done", "cancelled"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			var first *task.Task
+			var updated *task.Task
+			for i, status := range tt.statuses {
+				var err error
+				if updated, _, err = updateTask(s, 1, TaskChanges{Status: &status}); err != nil {
+					t.Fatalf("updateTask(%s): %v", status, err)
+				}
+				if i == 0 {
+					first = updated.Clone()
+				}
+			}
+
+			if (updated.Completed != nil) != tt.wantCompleted {
+				t.Fatalf("completed = %v, want set: %v", updated.Completed, tt.wantCompleted)
+			}
+			if tt.wantCompleted && first.Completed != nil && !updated.Completed.Equal(*first.Completed) {
+				t.Errorf("completed moved from %v to %v", first.Completed, updated.Completed)
+			}
+		})
+	}
+}
+
+func TestSystemTransitionsBypassWorkflow(t *testing.T) {
+	workflow := task.DefaultWorkflow()
+	workflow.Transitions = map[task.Status][]task.Status{task.StatusBacklog: {task.StatusNext}}
+	task.SetWorkflow(workflow)
+	t.Cleanup(func() { task.SetWorkflow(nil) })
+
+	done := string(task.StatusDone)
+
+	s := newTestStore(t)
+	_, _, err := updateTask(s, 1, TaskChanges{Status: &done})
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != ErrCodeConflict {
+		t.Fatalf("update backlog -> done = %v, want a conflict error", err)
+	}
+
+	updated, _, err := updateTask(s, 1, TaskChanges{Status: &done, System: true})
+	if err != nil {
+		t.Fatalf("system update backlog -> done: %v", err)
+	}
+	if updated.Status != task.StatusDone || updated.Completed == nil {
+		t.Errorf("after system update: status %s, completed %v", updated.Status, updated.Completed)
+	}
+}
+
+func TestUpdateArchivedTaskStatus(t *testing.T) {
+	s := newTestStore(t)
+	done, active := string(task.StatusDone), string(task.StatusActive)
+	if _, _, err := updateTask(s, 1, TaskChanges{Status: &done}); err != nil {
+		t.Fatalf("updateTask: %v", err)
+	}
+	if err := s.ArchiveTask(1); err != nil {
+		t.Fatalf("ArchiveTask: %v", err)
+	}
+
+	_, _, err := updateTask(s, 1, TaskChanges{Status: &active})
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != ErrCodeConflict || !strings.Contains(cmdErr.Hint, "task unarchive 1") {
+		t.Fatalf("reopening an archived task = %v, want a conflict pointing to unarchive", err)
+	}
+	if archived, err := s.ReadTask(1); err != nil || archived.Status != task.StatusDone {
+		t.Errorf("archived task after the refused update: %+v, %v", archived, err)
+	}
+
+	// Other changes, and setting the status it already has, are still allowed
+	note := "Still relevant"
+	if _, _, err := updateTask(s, 1, TaskChanges{Status: &done, Note: &note}); err != nil {
+		t.Errorf("noting an archived task: %v", err)
+	}
+}
@@ -0,0 +1,590 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// Webhook event types
+const (
+	EventTaskCreated    = "task.created"
+	EventTaskUpdated    = "task.updated"
+	EventTaskLinked     = "task.linked"
+	EventTaskUnlinked   = "task.unlinked"
+	EventTaskTagged     = "task.tagged"
+	EventTaskUntagged   = "task.untagged"
+	EventTaskMerged     = "task.merged"
+	EventTaskArchived   = "task.archived"
+	EventTaskUnarchived = "task.unarchived"
+	EventPing           = "ping" // Sent by 'task webhook test'
+)
+
+// validWebhookEvents returns the event types a webhook can subscribe to
+func validWebhookEvents() []string {
+	return []string{EventTaskCreated, EventTaskUpdated, EventTaskLinked, EventTaskUnlinked, EventTaskTagged, EventTaskUntagged, EventTaskMerged, EventTaskArchived, EventTaskUnarchived, EventPing}
+}
+
+const (
+	// serve delivers in the background, so it can afford to retry
+	webhookAttempts = 3
+	webhookTimeout  = 5 * time.Second
+
+	signatureHeader = "X-Task-Signature"
+)
+
+var (
+	// webhooksAsync makes deliveries run in the background with retries, so
+	// web API requests don't wait on slow endpoints. The CLI waits for its
+	// deliveries because the process exits when the command returns.
+	webhooksAsync bool
+
+	// pendingWebhooks tracks background deliveries so the server can wait for
+	// them on shutdown
+	pendingWebhooks sync.WaitGroup
+
+	webhookClient = &http.Client{}
+
+	// cliWebhookTimeout limits the one attempt the CLI makes: the user waits
+	// for deliveries, and missed ones are in 'webhook log'
+	cliWebhookTimeout = 2 * time.Second
+
+	// webhookBackoff is the wait before the first retry, doubled after each
+	// failed attempt
+	webhookBackoff = time.Second
+)
+
+// WebhookPayload is the JSON body posted to webhooks
+type WebhookPayload struct {
+	ID        string     `json:"id"`
+	Event     string     `json:"event"`
+	Timestamp time.Time  `json:"timestamp"`
+	Agent     string     `json:"agent"`
+	TaskID    int        `json:"task_id,omitempty"`
+	Before    *task.Task `json:"before"` // Null for task.created
+	After     *task.Task `json:"after"`
+	Changes   any        `json:"changes,omitempty"` // The command's JSON result data
+}
+
+// notifyWebhooks delivers an event to every webhook subscribed to it.
+// Delivery failures are logged, never returned: the change has already been
+// written and a webhook being down must not make the command fail.
+func notifyWebhooks(s *store.Store, event string, before, after *task.Task, changes any) {
+	config, err := s.ReadConfig()
+	if err != nil {
+		warnWebhook("%v", err)
+		return
+	}
+
+	var hooks []task.Webhook
+	for _, hook := range config.Webhooks {
+		if hook.WantsEvent(event) {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	payload, err := newWebhookPayload(event, before, after, changes)
+	if err != nil {
+		warnWebhook("%v", err)
+		return
+	}
+
+	// Webhooks are delivered in parallel, so the CLI waits for the slowest
+	// one rather than for all of them in turn
+	var delivered sync.WaitGroup
+	for _, hook := range hooks {
+		delivered.Add(1)
+		pendingWebhooks.Add(1)
+		go func() {
+			defer delivered.Done()
+			defer pendingWebhooks.Done()
+			deliverWebhook(s, hook, payload)
+		}()
+	}
+	if !webhooksAsync {
+		delivered.Wait()
+	}
+}
+
+func newWebhookPayload(event string, before, after *task.Task, changes any) (WebhookPayload, error) {
+	id, err := randomToken()
+	if err != nil {
+		return WebhookPayload{}, err
+	}
+
+	payload := WebhookPayload{
+		ID:        id[:32],
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Agent:     currentAgent(),
+		Before:    before,
+		After:     after,
+		Changes:   changes,
+	}
+	if after != nil {
+		payload.TaskID = after.ID
+	} else if before != nil {
+		payload.TaskID = before.ID
+	}
+	return payload, nil
+}
+
+// webhookLimits returns the number of attempts and the timeout of each
+// attempt: retries in the background under serve, one short try in the CLI
+func webhookLimits() (int, time.Duration) {
+	if webhooksAsync {
+		return webhookAttempts, webhookTimeout
+	}
+	return 1, cliWebhookTimeout
+}
+
+// deliverWebhook posts a payload to a webhook, retrying network errors, 5xx
+// and 429 responses with exponential backoff (see webhookLimits), and logs
+// the outcome
+func deliverWebhook(s *store.Store, hook task.Webhook, payload WebhookPayload) task.WebhookDelivery {
+	attempts, timeout := webhookLimits()
+
+	delivery := task.WebhookDelivery{
+		ID:        payload.ID,
+		Timestamp: time.Now(),
+		Event:     payload.Event,
+		TaskID:    payload.TaskID,
+		URL:       hook.URL,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		backoff := webhookBackoff
+		for delivery.Attempts < attempts {
+			if delivery.Attempts > 0 {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+			delivery.Attempts++
+
+			status, err := postWebhook(hook, payload, body, timeout)
+			delivery.Status = status
+			if err == nil && status >= 200 && status < 300 {
+				delivery.Delivered = true
+				delivery.Error = ""
+				break
+			}
+			if err != nil {
+				delivery.Error = err.Error()
+			} else {
+				delivery.Error = http.StatusText(status)
+				if status < 500 && status != http.StatusTooManyRequests {
+					break // The endpoint rejected the payload; retrying won't help
+				}
+			}
+		}
+	}
+
+	if !delivery.Delivered {
+		warnWebhook("delivery of %s to %s failed: %s", payload.Event, hook.URL, delivery.Error)
+	}
+	if err := s.AppendWebhookDelivery(delivery); err != nil {
+		warnWebhook("%v", err)
+	}
+	return delivery
+}
+
+// postWebhook makes one delivery attempt and returns the response status
+func postWebhook(hook task.Webhook, payload WebhookPayload, body []byte, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "task-webhook")
+	req.Header.Set("X-Task-Event", payload.Event)
+	req.Header.Set("X-Task-Delivery", payload.ID)
+	if secret := webhookSecret(hook); secret != "" {
+		req.Header.Set(signatureHeader, signPayload(secret, body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	return resp.StatusCode, nil
+}
+
+// webhookSecret resolves a webhook's secret, reading "env:NAME" secrets from
+// the environment so they need not be committed with the config
+func webhookSecret(hook task.Webhook) string {
+	if name, ok := strings.CutPrefix(hook.Secret, "env:"); ok {
+		return os.Getenv(name)
+	}
+	return hook.Secret
+}
+
+// signPayload returns the signature header value: "sha256=" followed by the
+// hex HMAC-SHA256 of the body
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// warnWebhook reports a delivery problem on stderr without failing the command
+func warnWebhook(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "Warning: webhook %s\n", fmt.Sprintf(format, args...))
+}
+
+// WebhooksResult is the JSON result of 'webhook list', 'add' and 'remove'
+type WebhooksResult struct {
+	Webhooks []task.Webhook `json:"webhooks"`
+}
+
+// WebhookDeliveriesResult is the JSON result of 'webhook test' and 'webhook log'
+type WebhookDeliveriesResult struct {
+	Deliveries []task.WebhookDelivery `json:"deliveries"`
+	Count      int                    `json:"count"`
+}
+
+func Webhook(args []string) error {
+	if len(args) < 1 {
+		return invalidArgf("usage: task webhook <add|list|remove|test|log> [options]")
+	}
+
+	switch args[0] {
+	case "add":
+		return webhookAdd(args[1:])
+	case "list":
+		return webhookList(args[1:])
+	case "remove":
+		return webhookRemove(args[1:])
+	case "test":
+		return webhookTest(args[1:])
+	case "log":
+		return webhookLog(args[1:])
+	default:
+		return invalidArgf("unknown webhook subcommand '%s' (must be: add, list, remove, test, log)", args[0])
+	}
+}
+
+func webhookAdd(args []string) error {
+	fs := newFlagSet("webhook add")
+	eventsFlag := fs.String("events", "", "Comma-separated event types to deliver (default: all)")
+	secretFlag := fs.String("secret", "", "Signing secret as env:NAME, read from $NAME when delivering")
+	insecureFlag := fs.Bool("insecure-secret", false, "Allow a literal --secret, stored in plain text in the committed config")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return invalidArgf("usage: task webhook add <url> [--events EVENTS] [--secret env:NAME]")
+	}
+	hookURL := fs.Arg(0)
+
+	// config.json is committed, so a literal secret would be readable by
+	// anyone with the repository
+	if *secretFlag != "" && !strings.HasPrefix(*secretFlag, "env:") && !*insecureFlag {
+		cmdErr := invalidArgf("--secret must be env:NAME; a literal secret would be committed in .tasks/config.json")
+		cmdErr.Hint = "Put the secret in an environment variable and pass --secret env:NAME, or pass --insecure-secret to store it anyway."
+		return cmdErr
+	}
+
+	u, err := url.Parse(hookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidArgf("invalid webhook URL '%s' (must be an http or https URL)", hookURL)
+	}
+
+	hook := task.Webhook{URL: hookURL, Secret: *secretFlag}
+	if *eventsFlag != "" {
+		for _, event := range strings.Split(*eventsFlag, ",") {
+			event = strings.TrimSpace(event)
+			if event != "*" && !isValidWebhookEvent(event) {
+				return invalidArgf("invalid event '%s' (must be one of: %s)", event, strings.Join(validWebhookEvents(), ", "))
+			}
+			hook.Events = append(hook.Events, event)
+		}
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	config, err := s.ReadConfig()
+	if err != nil {
+		return err
+	}
+	config.Webhooks = append(config.Webhooks, hook)
+	if err := s.WriteConfig(config); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("webhook", WebhooksResult{Webhooks: redactWebhooks(config.Webhooks)})
+	}
+
+	fmt.Printf("Added webhook %d: %s\n", len(config.Webhooks), hookURL)
+	return nil
+}
+
+func webhookList(args []string) error {
+	fs := newFlagSet("webhook list")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return invalidArgf("usage: task webhook list")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	config, err := s.ReadConfig()
+	if err != nil {
+		return err
+	}
+
+	hooks := redactWebhooks(config.Webhooks)
+	if jsonOutput {
+		return writeResult("webhook", WebhooksResult{Webhooks: hooks})
+	}
+
+	if len(hooks) == 0 {
+		fmt.Println("No webhooks configured")
+		return nil
+	}
+
+	for i, hook := range hooks {
+		events := "all events"
+		if len(hook.Events) > 0 {
+			events = strings.Join(hook.Events, ", ")
+		}
+		fmt.Printf("%d. %s (%s)", i+1, hook.URL, events)
+		if hook.Secret != "" {
+			fmt.Printf(" [signed]")
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func webhookRemove(args []string) error {
+	fs := newFlagSet("webhook remove")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return invalidArgf("usage: task webhook remove <number>")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	config, err := s.ReadConfig()
+	if err != nil {
+		return err
+	}
+
+	n, err := webhookNumber(fs.Arg(0), config)
+	if err != nil {
+		return err
+	}
+
+	removed := config.Webhooks[n-1]
+	config.Webhooks = append(config.Webhooks[:n-1], config.Webhooks[n:]...)
+	if err := s.WriteConfig(config); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeResult("webhook", WebhooksResult{Webhooks: redactWebhooks(config.Webhooks)})
+	}
+
+	fmt.Printf("Removed webhook %d: %s\n", n, removed.URL)
+	return nil
+}
+
+// webhookTest sends a ping event to one webhook, or all of them, and reports
+// the outcome. Unlike task events, it fails when a delivery fails.
+func webhookTest(args []string) error {
+	fs := newFlagSet("webhook test")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return invalidArgf("usage: task webhook test [number]")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	config, err := s.ReadConfig()
+	if err != nil {
+		return err
+	}
+	if len(config.Webhooks) == 0 {
+		return notFoundf("no webhooks configured")
+	}
+
+	hooks := config.Webhooks
+	if fs.NArg() > 0 {
+		n, err := webhookNumber(fs.Arg(0), config)
+		if err != nil {
+			return err
+		}
+		hooks = hooks[n-1 : n]
+	}
+
+	payload, err := newWebhookPayload(EventPing, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []task.WebhookDelivery
+	failed := 0
+	for _, hook := range hooks {
+		delivery := deliverWebhook(s, hook, payload)
+		deliveries = append(deliveries, delivery)
+		if !delivery.Delivered {
+			failed++
+		}
+	}
+
+	result := WebhookDeliveriesResult{Deliveries: deliveries, Count: len(deliveries)}
+	if jsonOutput {
+		if failed > 0 {
+			cmdErr := newError(ErrCodeIO, "%d of %d webhook deliveries failed", failed, len(deliveries))
+			cmdErr.Data = result
+			return cmdErr
+		}
+		return writeResult("webhook", result)
+	}
+
+	for _, d := range deliveries {
+		fmt.Println(formatDelivery(d))
+	}
+	if failed > 0 {
+		return newError(ErrCodeIO, "%d of %d webhook deliveries failed", failed, len(deliveries))
+	}
+	return nil
+}
+
+func webhookLog(args []string) error {
+	fs := newFlagSet("webhook log")
+	limitFlag := fs.Int("limit", 20, "Show at most this many recent deliveries (0 for all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := s.ReadWebhookDeliveries()
+	if err != nil {
+		return err
+	}
+	if *limitFlag > 0 && len(deliveries) > *limitFlag {
+		deliveries = deliveries[len(deliveries)-*limitFlag:]
+	}
+	if deliveries == nil {
+		deliveries = []task.WebhookDelivery{}
+	}
+
+	if jsonOutput {
+		return writeResult("webhook", WebhookDeliveriesResult{Deliveries: deliveries, Count: len(deliveries)})
+	}
+
+	if len(deliveries) == 0 {
+		fmt.Println("No webhook deliveries")
+		return nil
+	}
+
+	for _, d := range deliveries {
+		fmt.Println(formatDelivery(d))
+	}
+	return nil
+}
+
+// formatDelivery formats a delivery log entry as a single line
+func formatDelivery(d task.WebhookDelivery) string {
+	outcome := "delivered"
+	if !d.Delivered {
+		outcome = "FAILED: " + d.Error
+	}
+	if d.Status != 0 {
+		outcome = fmt.Sprintf("%d %s", d.Status, outcome)
+	}
+
+	subject := d.Event
+	if d.TaskID != 0 {
+		subject = fmt.Sprintf("%s #%d", d.Event, d.TaskID)
+	}
+
+	return fmt.Sprintf("[%s] %s -> %s: %s (%d attempt(s))", d.Timestamp.Format("2006-01-02 15:04:05"), subject, d.URL, outcome, d.Attempts)
+}
+
+// webhookNumber parses a 1-based webhook number as shown by 'webhook list'
+func webhookNumber(arg string, config *task.Config) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, invalidArgf("invalid webhook number '%s'", arg)
+	}
+	if n < 1 || n > len(config.Webhooks) {
+		return 0, notFoundf("webhook %d not found", n)
+	}
+	return n, nil
+}
+
+func isValidWebhookEvent(event string) bool {
+	for _, valid := range validWebhookEvents() {
+		if event == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// redactWebhooks hides literal secrets from output; env:NAME references are
+// shown as they are
+func redactWebhooks(hooks []task.Webhook) []task.Webhook {
+	result := make([]task.Webhook, len(hooks))
+	for i, hook := range hooks {
+		if hook.Secret != "" && !strings.HasPrefix(hook.Secret, "env:") {
+			hook.Secret = "********"
+		}
+		result[i] = hook
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+func TestDeliverWebhook(t *testing.T) {
+	webhookBackoff, cliWebhookTimeout = time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() {
+		webhookBackoff, cliWebhookTimeout = time.Second, 2*time.Second
+		webhooksAsync = false
+	})
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+
+	tests := []struct {
+		name          string
+		serve         bool          // Deliver as under serve, with retries
+		delay         time.Duration // Before each response
+		statuses      []int         // Response to each attempt; the last one repeats
+		wantAttempts  int
+		wantDelivered bool
+		wantStatus    int
+	}{
+		{"delivered", true, 0, []int{200}, 1, true, 200},
+		{"retried after 5xx", true, 0, []int{500, 503, 200}, 3, true, 200},
+		{"retried after 429", true, 0, []int{429, 204}, 2, true, 204},
+		{"gives up after 3 attempts", true, 0, []int{502}, 3, false, 502},
+		{"4xx is not retried", true, 0, []int{400}, 1, false, 400},
+		{"CLI delivers", false, 0, []int{200}, 1, true, 200},
+		{"CLI doesn't retry", false, 0, []int{503, 200}, 1, false, 503},
+		{"CLI gives up on a slow webhook", false, 300 * time.Millisecond, []int{200}, 1, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhooksAsync = tt.serve
+			var mu sync.Mutex
+			attempts := 0
+			var badSignature string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				if got, want := r.Header.Get(signatureHeader), signPayload("s3cret", body); got != want {
+					badSignature = got
+				}
+				status := tt.statuses[min(attempts, len(tt.statuses)-1)]
+				attempts++
+				mu.Unlock()
+
+				time.Sleep(tt.delay)
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			s := store.New(t.TempDir())
+			if err := s.Init(); err != nil {
+				t.Fatalf("Init: %v", err)
+			}
+
+			hook := task.Webhook{URL: server.URL, Secret: "env:TEST_WEBHOOK_SECRET"}
+			after := &task.Task{ID: 7, Title: "Webhook test", Status: task.StatusActive}
+			payload, err := newWebhookPayload(EventTaskUpdated, nil, after, nil)
+			if err != nil {
+				t.Fatalf("newWebhookPayload: %v", err)
+			}
+
+			delivery := deliverWebhook(s, hook, payload)
+
+			if badSignature != "" {
+				t.Errorf("signature header = %q, want HMAC-SHA256 of the body", badSignature)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if attempts != tt.wantAttempts || delivery.Attempts != tt.wantAttempts {
+				t.Errorf("attempts: server saw %d, delivery says %d, want %d", attempts, delivery.Attempts, tt.wantAttempts)
+			}
+			if delivery.Delivered != tt.wantDelivered || delivery.Status != tt.wantStatus {
+				t.Errorf("delivery = delivered %v status %d, want %v %d", delivery.Delivered, delivery.Status, tt.wantDelivered, tt.wantStatus)
+			}
+
+			logged, err := s.ReadWebhookDeliveries()
+			if err != nil {
+				t.Fatalf("ReadWebhookDeliveries: %v", err)
+			}
+			if len(logged) != 1 {
+				t.Fatalf("log has %d entries, want 1", len(logged))
+			}
+			got := logged[0]
+			if got.ID != payload.ID || got.TaskID != 7 || got.Event != EventTaskUpdated || got.Attempts != tt.wantAttempts || got.Delivered != tt.wantDelivered {
+				t.Errorf("log entry = %+v", got)
+			}
+		})
+	}
+}
+
+func TestWebhookLogIsIgnored(t *testing.T) {
+	root := t.TempDir()
+	s := store.New(root)
+	if err := s.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := s.AppendWebhookDelivery(task.WebhookDelivery{ID: "1", Event: EventPing}); err != nil {
+		t.Fatalf("AppendWebhookDelivery: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, store.TasksDir, store.CacheDir, store.WebhookLogFile)); err != nil {
+		t.Errorf("log not in the cache directory: %v", err)
+	}
+	ignore, err := os.ReadFile(filepath.Join(root, store.TasksDir, store.GitignoreFile))
+	if err != nil {
+		t.Fatalf("reading .gitignore: %v", err)
+	}
+	if !strings.Contains(string(ignore), store.CacheDir+"/") {
+		t.Errorf(".gitignore = %q, want it to ignore %s/", ignore, store.CacheDir)
+	}
+}
+
+func TestWebhookAddRefusesLiteralSecret(t *testing.T) {
+	err := webhookAdd([]string{"https://example.com/hook", "--secret", "s3cret"})
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != ErrCodeInvalidArgument {
+		t.Fatalf("webhookAdd with a literal secret = %v, want an invalid_argument error", err)
+	}
+}
+
+func TestWebhookTestReportsFailedDeliveries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	s := newTestStore(t)
+	config, err := s.ReadConfig()
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+	config.Webhooks = []task.Webhook{{URL: server.URL}}
+	if err := s.WriteConfig(config); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+	t.Chdir(s.Root())
+	SetJSONOutput(true)
+	t.Cleanup(func() { SetJSONOutput(false) })
+
+	err = webhookTest(nil)
+	cmdErr := classifyError(err)
+	result, ok := cmdErr.Data.(WebhookDeliveriesResult)
+	if !ok || result.Count != 1 {
+		t.Fatalf("webhookTest error data = %#v, want the deliveries", cmdErr.Data)
+	}
+	if d := result.Deliveries[0]; d.Delivered || d.Status != http.StatusBadGateway || d.Error == "" {
+		t.Errorf("delivery = %+v, want the failed status and error", d)
+	}
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/onuse/tasks/internal/store"
+	"github.com/onuse/tasks/internal/task"
+)
+
+// WIPUsage is how much of a status's WIP limit is used
+type WIPUsage struct {
+	Status     task.Status `json:"status"`
+	Count      int         `json:"count"`
+	Limit      int         `json:"limit,omitempty"`
+	Agent      string      `json:"agent,omitempty"`
+	AgentCount int         `json:"agent_count,omitempty"`
+	AgentLimit int         `json:"agent_limit,omitempty"`
+}
+
+// Exceeded describes the limit moving one more task into the status would
+// break, or returns "" if it stays within its limits
+func (u WIPUsage) Exceeded() string {
+	if u.Limit > 0 && u.Count >= u.Limit {
+		return fmt.Sprintf("%s has %d of %d tasks", u.Status, u.Count, u.Limit)
+	}
+	if u.AgentLimit > 0 && u.AgentCount >= u.AgentLimit {
+		return fmt.Sprintf("%s has %d of %d %s tasks", u.Agent, u.AgentCount, u.AgentLimit, u.Status)
+	}
+	return ""
+}
+
+// validateWIPLimits checks that limits are set on workflow statuses
+func validateWIPLimits(limits map[task.Status]task.WIPLimit) error {
+	for status, limit := range limits {
+		if _, ok := task.CurrentWorkflow().Status(status); !ok {
+			return fmt.Errorf("WIP limit on unknown status '%s'", status)
+		}
+		if limit.Total < 0 || limit.PerAgent < 0 {
+			return fmt.Errorf("WIP limit on '%s' is negative", status)
+		}
+	}
+	return nil
+}
+
+// wipUsage counts the tasks in a limited status, in total and for agent.
+// Only the per-agent count needs task files, to see who moved each task in.
+func wipUsage(s *store.Store, index *task.Index, status task.Status, limit task.WIPLimit, agent string) WIPUsage {
+	usage := WIPUsage{Status: status, Limit: limit.Total}
+	if limit.PerAgent > 0 {
+		usage.Agent = agent
+		usage.AgentLimit = limit.PerAgent
+	}
+
+	for _, entry := range index.Tasks {
+		if entry.Status != status {
+			continue
+		}
+		usage.Count++
+
+		if usage.AgentLimit > 0 {
+			t, err := s.ReadTask(entry.ID)
+			if err == nil && t.StatusBy == agent {
+				usage.AgentCount++
+			}
+		}
+	}
+	return usage
+}
+
+// wipUsages returns the usage of every WIP limit in workflow order
+func wipUsages(s *store.Store, index *task.Index, agent string) ([]WIPUsage, error) {
+	config, err := s.ReadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []WIPUsage
+	for status, limit := range config.WIPLimits {
+		if limit.Total > 0 || limit.PerAgent > 0 {
+			usages = append(usages, wipUsage(s, index, status, limit, agent))
+		}
+	}
+
+	order := map[task.Status]int{}
+	for i, status := range task.CurrentWorkflow().Statuses {
+		order[status.Name] = i
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return order[usages[i].Status] < order[usages[j].Status]
+	})
+	return usages, nil
+}
+
+// checkWIPLimit returns a wip_limit error if moving a task into status would
+// exceed a limit. With force the move is allowed, and the returned text
+// describes the limit being exceeded so it can be noted on the task.
+func checkWIPLimit(s *store.Store, status task.Status, agent string, force bool) (string, error) {
+	config, err := s.ReadConfig()
+	if err != nil {
+		return "", err
+	}
+	limit, ok := config.WIPLimits[status]
+	if !ok || (limit.Total == 0 && limit.PerAgent == 0) {
+		return "", nil
+	}
+
+	index, err := s.ReadIndex()
+	if err != nil {
+		return "", err
+	}
+
+	exceeded := wipUsage(s, index, status, limit, agent).Exceeded()
+	if exceeded == "" || force {
+		return exceeded, nil
+	}
+
+	cmdErr := newError(ErrCodeWIPLimit, "WIP limit reached: %s", exceeded)
+	cmdErr.Hint = "Finish or move a task first, or pass --force to exceed the limit (recorded in a note)."
+	return "", cmdErr
+}
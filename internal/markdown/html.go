@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// langPattern limits code block languages to safe class name characters
+var langPattern = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+
+// ToHTML renders Markdown as sanitized HTML. All text is escaped, raw HTML is
+// shown literally, and links are only kept for http, https and mailto URLs
+// or relative paths. Task references become
+// <a class="task-ref" data-task="123"> so the web UI can open the task.
+func ToHTML(src string) string {
+	var b strings.Builder
+	writeHTMLBlocks(&b, parse(src), false)
+	return b.String()
+}
+
+// writeHTMLBlocks renders blocks. In a tight list item, a leading paragraph is
+// written without <p> tags.
+func writeHTMLBlocks(b *strings.Builder, blocks []block, tight bool) {
+	for i, bl := range blocks {
+		switch bl.kind {
+		case blockParagraph:
+			if tight && i == 0 {
+				writeHTMLInline(b, parseInline(bl.text))
+				if len(blocks) > 1 {
+					b.WriteString("\n")
+				}
+				continue
+			}
+			b.WriteString("<p>")
+			writeHTMLInline(b, parseInline(bl.text))
+			b.WriteString("</p>\n")
+
+		case blockHeading:
+			fmt.Fprintf(b, "<h%d>", bl.level)
+			writeHTMLInline(b, parseInline(bl.text))
+			fmt.Fprintf(b, "</h%d>\n", bl.level)
+
+		case blockCode:
+			b.WriteString("<pre><code")
+			if bl.lang != "" && langPattern.MatchString(bl.lang) {
+				fmt.Fprintf(b, ` class="language-%s"`, bl.lang)
+			}
+			b.WriteString(">")
+			b.WriteString(html.EscapeString(bl.text))
+			b.WriteString("</code></pre>\n")
+
+		case blockQuote:
+			b.WriteString("<blockquote>\n")
+			writeHTMLBlocks(b, bl.children, false)
+			b.WriteString("</blockquote>\n")
+
+		case blockRule:
+			b.WriteString("<hr>\n")
+
+		case blockList:
+			writeHTMLList(b, bl)
+		}
+	}
+}
+
+func writeHTMLList(b *strings.Builder, bl block) {
+	tag := "ul"
+	if bl.ordered {
+		tag = "ol"
+	}
+
+	hasTasks := false
+	for _, it := range bl.items {
+		hasTasks = hasTasks || it.task
+	}
+
+	b.WriteString("<" + tag)
+	if bl.ordered && bl.start != 1 {
+		fmt.Fprintf(b, ` start="%d"`, bl.start)
+	}
+	if hasTasks {
+		b.WriteString(` class="task-list"`)
+	}
+	b.WriteString(">\n")
+
+	for _, it := range bl.items {
+		if it.task {
+			b.WriteString(`<li class="task-list-item"><input type="checkbox" disabled`)
+			if it.checked {
+				b.WriteString(" checked")
+			}
+			b.WriteString("> ")
+		} else {
+			b.WriteString("<li>")
+		}
+		writeHTMLBlocks(b, it.blocks, true)
+		b.WriteString("</li>\n")
+	}
+
+	b.WriteString("</" + tag + ">\n")
+}
+
+func writeHTMLInline(b *strings.Builder, inlines []inline) {
+	for _, in := range inlines {
+		switch in.kind {
+		case inlineText:
+			b.WriteString(html.EscapeString(in.text))
+		case inlineCode:
+			b.WriteString("<code>" + html.EscapeString(in.text) + "</code>")
+		case inlineStrong:
+			b.WriteString("<strong>")
+			writeHTMLInline(b, in.children)
+			b.WriteString("</strong>")
+		case inlineEmphasis:
+			b.WriteString("<em>")
+			writeHTMLInline(b, in.children)
+			b.WriteString("</em>")
+		case inlineLink:
+			if !isSafeURL(in.url) {
+				writeHTMLInline(b, in.children)
+				continue
+			}
+			fmt.Fprintf(b, `<a href="%s" target="_blank" rel="noopener noreferrer nofollow">`, html.EscapeString(in.url))
+			writeHTMLInline(b, in.children)
+			b.WriteString("</a>")
+		case inlineTaskRef:
+			fmt.Fprintf(b, `<a href="#" class="task-ref" data-task="%d">#%d</a>`, in.taskID, in.taskID)
+		case inlineBreak:
+			b.WriteString("<br>\n")
+		case inlineSoftBreak:
+			b.WriteString("\n")
+		}
+	}
+}
+
+// isSafeURL allows web and mail links and relative URLs, rejecting schemes
+// such as javascript: and data:
+func isSafeURL(url string) bool {
+	lower := strings.ToLower(strings.TrimSpace(url))
+	if lower == "" {
+		return false
+	}
+
+	colon := strings.IndexByte(lower, ':')
+	if colon < 0 {
+		return true
+	}
+	// A colon after a path, query or fragment separator isn't a scheme
+	if sep := strings.IndexAny(lower, "/?#"); sep >= 0 && sep < colon {
+		return true
+	}
+
+	switch lower[:colon] {
+	case "http", "https", "mailto":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,171 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestIsSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/a?b=c#d", true},
+		{"http://example.com", true},
+		{"mailto:ann@example.com", true},
+		{"HTTPS://EXAMPLE.COM", true},
+		{"/docs/setup.md", true},
+		{"docs/setup.md", true},
+		{"#section", true},
+		{"?page=2", true},
+		{"docs/a:b.md", true}, // The colon comes after a path separator
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{"JAVASCRIPT:alert(1)", false},
+		{"  javascript:alert(1)", false},
+		{"\tjavascript:alert(1)", false},
+		{"\njavascript:alert(1)", false},
+		{"java\tscript:alert(1)", false},
+		{"&#106;avascript:alert(1)", true}, // A fragment; the href is escaped, so the entity is never decoded
+		{"data:text/html,<script>alert(1)</script>", false},
+		{"DATA:text/html;base64,PHNjcmlwdD4=", false},
+		{" data:image/svg+xml,<svg onload=alert(1)>", false},
+		{"vbscript:msgbox(1)", false},
+		{"file:///etc/passwd", false},
+		{"", false},
+		{"   ", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSafeURL(tt.url); got != tt.want {
+			t.Errorf("isSafeURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "safe link",
+			src:  "[docs](https://example.com/docs)",
+			want: `<p><a href="https://example.com/docs" target="_blank" rel="noopener noreferrer nofollow">docs</a></p>` + "\n",
+		},
+		{
+			name: "javascript link keeps only its text",
+			src:  "[click](javascript:alert(1))",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "mixed case javascript link",
+			src:  "[click](JaVaScRiPt:alert(1))",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "javascript link after whitespace",
+			src:  "[click](   javascript:alert(1))",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "javascript link in angle brackets",
+			src:  "[click](<javascript:alert(1)>)",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "data link",
+			src:  "[img](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+			want: "<p>img</p>\n",
+		},
+		{
+			name: "escaped colon is not a scheme",
+			src:  "[click](javascript&colon;alert(1))",
+			want: `<p><a href="javascript&amp;colon;alert(1)" target="_blank" rel="noopener noreferrer nofollow">click</a></p>` + "\n",
+		},
+		{
+			name: "quotes in a URL can't leave the attribute",
+			src:  `[x](https://example.com/"onmouseover="alert(1))`,
+			want: `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1)" target="_blank" rel="noopener noreferrer nofollow">x</a></p>` + "\n",
+		},
+		{
+			name: "angle brackets and ampersands in a bare URL",
+			src:  "https://example.com/?a=1&b='2'&c",
+			want: `<p><a href="https://example.com/?a=1&amp;b=&#39;2&#39;&amp;c" target="_blank" rel="noopener noreferrer nofollow">https://example.com/?a=1&amp;b=&#39;2&#39;&amp;c</a></p>` + "\n",
+		},
+		{
+			name: "raw HTML is shown as text",
+			src:  `<script>alert("x")</script>`,
+			want: "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>\n",
+		},
+		{
+			name: "raw HTML with event handler",
+			src:  `<img src=x onerror="alert(1)">`,
+			want: "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>\n",
+		},
+		{
+			name: "raw HTML in a heading, emphasis and link text",
+			src:  "# <b>Title</b>\n\n**<i>bold</i>** [<u>x</u>](/a)",
+			want: "<h1>&lt;b&gt;Title&lt;/b&gt;</h1>\n" +
+				`<p><strong>&lt;i&gt;bold&lt;/i&gt;</strong> <a href="/a" target="_blank" rel="noopener noreferrer nofollow">&lt;u&gt;x&lt;/u&gt;</a></p>` + "\n",
+		},
+		{
+			name: "raw HTML in code",
+			src:  "`<b>`\n\n```html\n<script></script>\n```",
+			want: "<p><code>&lt;b&gt;</code></p>\n<pre><code class=\"language-html\">&lt;script&gt;&lt;/script&gt;</code></pre>\n",
+		},
+		{
+			name: "code block language can't add attributes",
+			src:  "```\" onclick=\"alert(1)\nx\n```",
+			want: "<pre><code>x</code></pre>\n",
+		},
+		{
+			name: "task references",
+			src:  "See #12 and #3, not a#4 or #5x",
+			want: `<p>See <a href="#" class="task-ref" data-task="12">#12</a> and <a href="#" class="task-ref" data-task="3">#3</a>, not a#4 or #5x</p>` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToHTML(tt.src); got != tt.want {
+				t.Errorf("ToHTML(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// No input may produce a tag the renderer doesn't write itself, or a link
+// to anything but the input's safe URLs
+func TestToHTMLNeverPassesMarkupThrough(t *testing.T) {
+	inputs := []string{
+		`<svg/onload=alert(1)>`,
+		`<a href="javascript:alert(1)">x</a>`,
+		`[x](javascript:alert(1) "title")`,
+		`[x](data:text/html,<script>alert(1)</script>)`,
+		`<javascript:alert(1)>`,
+		"> <iframe src=x>\n> - <object>",
+		"- [ ] <script>\n- [x] <style>",
+		"# [<img src=x onerror=alert(1)>](https://example.com)",
+	}
+	allowed := map[string]bool{
+		"p": true, "h1": true, "pre": true, "code": true, "blockquote": true, "hr": true,
+		"ul": true, "ol": true, "li": true, "input": true, "strong": true, "em": true, "a": true, "br": true,
+	}
+
+	for _, src := range inputs {
+		got := ToHTML(src)
+		for _, m := range tagPattern.FindAllStringSubmatch(got, -1) {
+			if !allowed[strings.ToLower(m[1])] {
+				t.Errorf("ToHTML(%q) = %q, contains a <%s> tag", src, got, m[1])
+			}
+		}
+		if strings.Contains(got, `href="javascript`) || strings.Contains(got, `href="data`) {
+			t.Errorf("ToHTML(%q) = %q, links to an unsafe URL", src, got)
+		}
+	}
+}
+
+var tagPattern = regexp.MustCompile(`</?([A-Za-z][A-Za-z0-9]*)`)
@@ -0,0 +1,307 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+type inlineKind int
+
+const (
+	inlineText inlineKind = iota
+	inlineCode
+	inlineStrong
+	inlineEmphasis
+	inlineLink
+	inlineTaskRef
+	inlineBreak // Hard line break
+	inlineSoftBreak
+)
+
+// inline is a parsed inline element
+type inline struct {
+	kind     inlineKind
+	text     string // Text, code span contents, or autolink text
+	url      string // Link destination
+	taskID   int    // Task reference
+	children []inline
+}
+
+// parseInline splits paragraph or heading text into inline elements
+func parseInline(s string) []inline {
+	var result []inline
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			result = append(result, inline{kind: inlineText, text: text.String()})
+			text.Reset()
+		}
+	}
+	emit := func(in inline) {
+		flush()
+		result = append(result, in)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			emit(inline{kind: inlineBreak})
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '\n':
+			if strings.HasSuffix(text.String(), "  ") {
+				trimmed := strings.TrimRight(text.String(), " ")
+				text.Reset()
+				text.WriteString(trimmed)
+				emit(inline{kind: inlineBreak})
+			} else {
+				emit(inline{kind: inlineSoftBreak})
+			}
+			i++
+			continue
+
+		case c == '`':
+			run := countRun(s, i, '`')
+			if end := strings.Index(s[i+run:], strings.Repeat("`", run)); end >= 0 {
+				code := s[i+run : i+run+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				emit(inline{kind: inlineCode, text: strings.ReplaceAll(code, "\n", " ")})
+				i += run + end + run
+				continue
+			}
+			text.WriteString(s[i : i+run])
+			i += run
+			continue
+
+		case c == '*' || c == '_':
+			if in, n := parseEmphasis(s, i); n > 0 {
+				emit(in)
+				i += n
+				continue
+			}
+			run := countRun(s, i, c)
+			text.WriteString(s[i : i+run])
+			i += run
+			continue
+
+		case c == '[':
+			if in, n := parseLink(s, i); n > 0 {
+				emit(in)
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				target := s[i+1 : i+end]
+				if isAutolink(target) {
+					emit(inline{kind: inlineLink, url: target, children: []inline{{kind: inlineText, text: target}}})
+					i += end + 1
+					continue
+				}
+			}
+
+		case c == 'h' && (i == 0 || !isWordChar(s[i-1])) && (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")):
+			n := bareURLLength(s[i:])
+			target := s[i : i+n]
+			emit(inline{kind: inlineLink, url: target, children: []inline{{kind: inlineText, text: target}}})
+			i += n
+			continue
+
+		case c == '#' && (i == 0 || !isWordChar(s[i-1])):
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			if j > i+1 && (j == len(s) || !isWordChar(s[j])) {
+				id, err := strconv.Atoi(s[i+1 : j])
+				if err == nil {
+					emit(inline{kind: inlineTaskRef, taskID: id})
+					i = j
+					continue
+				}
+			}
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+
+	flush()
+	return result
+}
+
+// parseEmphasis parses *em*, _em_, **strong** or __strong__ at s[i]. It
+// returns the element and the number of bytes consumed, or 0 if there is no
+// closing delimiter. Underscores inside words (snake_case) are left alone.
+func parseEmphasis(s string, i int) (inline, int) {
+	c := s[i]
+	if c == '_' && i > 0 && isWordChar(s[i-1]) {
+		return inline{}, 0
+	}
+
+	run := countRun(s, i, c)
+	if run > 2 {
+		run = 2
+	}
+	delim := strings.Repeat(string(c), run)
+
+	start := i + run
+	if start >= len(s) || s[start] == ' ' || s[start] == '\n' {
+		return inline{}, 0 // Opening delimiter must not be followed by whitespace
+	}
+
+	for j := start; j < len(s); j++ {
+		if s[j] == '`' { // Don't close inside code spans
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(s[j:], delim) || j == start {
+			continue
+		}
+		if s[j-1] == ' ' || s[j-1] == '\n' {
+			continue // Closing delimiter must not follow whitespace
+		}
+		if run == 1 && j+1 < len(s) && s[j+1] == c {
+			j++ // Part of a longer run; not our closer
+			continue
+		}
+		if c == '_' && j+run < len(s) && isWordChar(s[j+run]) {
+			continue
+		}
+
+		kind := inlineEmphasis
+		if run == 2 {
+			kind = inlineStrong
+		}
+		return inline{kind: kind, children: parseInline(s[start:j])}, j + run - i
+	}
+
+	return inline{}, 0
+}
+
+// parseLink parses [text](url) at s[i]
+func parseLink(s string, i int) (inline, int) {
+	depth := 0
+	closeText := -1
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeText = j
+			}
+		}
+		if closeText >= 0 {
+			break
+		}
+	}
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return inline{}, 0
+	}
+
+	// The destination may contain balanced parentheses
+	end := -1
+	parens := 0
+	for j := closeText + 2; j < len(s) && end < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '(':
+			parens++
+		case ')':
+			if parens == 0 {
+				end = j - (closeText + 2)
+			}
+			parens--
+		}
+	}
+	if end < 0 {
+		return inline{}, 0
+	}
+	dest := strings.TrimSpace(s[closeText+2 : closeText+2+end])
+	if fields := strings.Fields(dest); len(fields) > 0 {
+		dest = fields[0] // Drop an optional title
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+
+	return inline{
+		kind:     inlineLink,
+		url:      dest,
+		children: parseInline(s[i+1 : closeText]),
+	}, closeText + 2 + end + 1 - i
+}
+
+// bareURLLength returns the length of a URL written without angle brackets,
+// leaving off trailing punctuation that most likely ends the sentence
+func bareURLLength(s string) int {
+	n := 0
+	for n < len(s) && s[n] != ' ' && s[n] != '\n' && s[n] != '<' {
+		n++
+	}
+	for n > 0 && strings.IndexByte(".,:;!?'\")", s[n-1]) >= 0 {
+		if s[n-1] == ')' && strings.Count(s[:n], "(") >= strings.Count(s[:n], ")") {
+			break
+		}
+		n--
+	}
+	return n
+}
+
+func isAutolink(target string) bool {
+	if strings.ContainsAny(target, " \n<") {
+		return false
+	}
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "mailto:")
+}
+
+func countRun(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// plainText returns the text content of inline elements
+func plainText(inlines []inline) string {
+	var b strings.Builder
+	for _, in := range inlines {
+		switch in.kind {
+		case inlineText, inlineCode:
+			b.WriteString(in.text)
+		case inlineTaskRef:
+			b.WriteString("#" + strconv.Itoa(in.taskID))
+		case inlineBreak, inlineSoftBreak:
+			b.WriteString(" ")
+		default:
+			b.WriteString(plainText(in.children))
+		}
+	}
+	return b.String()
+}
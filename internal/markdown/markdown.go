@@ -0,0 +1,247 @@
+// Package markdown renders the Markdown used in task descriptions and notes.
+//
+// It supports a CommonMark subset: ATX headings, paragraphs, fenced code
+// blocks, block quotes, nested bullet and ordered lists, thematic breaks,
+// code spans, emphasis, links and autolinks. Two GitHub-style extensions are
+// included: task-list items ("- [ ]" and "- [x]") and "#123" references to
+// other tasks. Raw HTML is never passed through.
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockCode
+	blockQuote
+	blockList
+	blockRule
+)
+
+// block is a parsed block-level element
+type block struct {
+	kind     blockKind
+	text     string  // Paragraph and heading inline text, or code block contents
+	level    int     // Heading level
+	lang     string  // Code block info string
+	ordered  bool    // Ordered list
+	start    int     // First number of an ordered list
+	items    []item  // List items
+	children []block // Block quote contents
+}
+
+// item is a list item
+type item struct {
+	task    bool // Task-list item with a checkbox
+	checked bool
+	blocks  []block
+}
+
+var (
+	headingPattern  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	fencePattern    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	rulePattern     = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	listPattern     = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	quotePattern    = regexp.MustCompile(`^ {0,3}> ?`)
+	taskItemPattern = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+|$)`)
+)
+
+// parse splits Markdown source into blocks
+func parse(src string) []block {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	return parseBlocks(strings.Split(src, "\n"))
+}
+
+func parseBlocks(lines []string) []block {
+	var blocks []block
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlank(line):
+			i++
+
+		case fencePattern.MatchString(line):
+			b, next := parseFence(lines, i)
+			blocks = append(blocks, b)
+			i = next
+
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			blocks = append(blocks, block{kind: blockHeading, level: len(m[1]), text: m[2]})
+			i++
+
+		case rulePattern.MatchString(line):
+			blocks = append(blocks, block{kind: blockRule})
+			i++
+
+		case quotePattern.MatchString(line):
+			var inner []string
+			for i < len(lines) && quotePattern.MatchString(lines[i]) {
+				inner = append(inner, quotePattern.ReplaceAllString(lines[i], ""))
+				i++
+			}
+			blocks = append(blocks, block{kind: blockQuote, children: parseBlocks(inner)})
+
+		case listPattern.MatchString(line):
+			b, next := parseList(lines, i)
+			blocks = append(blocks, b)
+			i = next
+
+		default:
+			var para []string
+			for i < len(lines) && !isBlank(lines[i]) && (len(para) == 0 || !startsBlock(lines[i])) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			blocks = append(blocks, block{kind: blockParagraph, text: strings.Join(para, "\n")})
+		}
+	}
+
+	return blocks
+}
+
+// parseFence parses a fenced code block starting at lines[start]
+func parseFence(lines []string, start int) (block, int) {
+	m := fencePattern.FindStringSubmatch(lines[start])
+	indent, fence := len(m[1]), m[2]
+	b := block{kind: blockCode}
+	if fields := strings.Fields(m[3]); len(fields) > 0 {
+		b.lang = fields[0]
+	}
+
+	var code []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		// Remove up to the fence's own indentation
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+
+	b.text = strings.Join(code, "\n")
+	return b, i
+}
+
+// parseList parses a list starting at lines[start]. Item content is every
+// following line indented past the marker, parsed recursively, so lists nest.
+func parseList(lines []string, start int) (block, int) {
+	first := listPattern.FindStringSubmatch(lines[start])
+	marker := first[2]
+	b := block{kind: blockList, ordered: isOrderedMarker(marker)}
+	if b.ordered {
+		b.start, _ = strconv.Atoi(marker[:len(marker)-1])
+	}
+	delimiter := marker[len(marker)-1:]
+
+	i := start
+	for i < len(lines) {
+		m := listPattern.FindStringSubmatch(lines[i])
+		if m == nil || isOrderedMarker(m[2]) != b.ordered || !strings.HasSuffix(m[2], delimiter) {
+			break
+		}
+
+		contentIndent := len(m[0])
+		if m[3] == "" || len(m[3]) > 4 {
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		}
+		content := []string{strings.TrimLeft(lines[i][min(len(m[0]), len(lines[i])):], " ")}
+		i++
+
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				// A blank line continues the item only if indented content follows
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j < len(lines) && indentOf(lines[j]) >= contentIndent {
+					for ; i < j; i++ {
+						content = append(content, "")
+					}
+					continue
+				}
+				break
+			}
+			if indentOf(line) >= contentIndent {
+				content = append(content, line[contentIndent:])
+				i++
+				continue
+			}
+			// Lazy continuation of the item's paragraph
+			if !startsBlock(line) && !listPattern.MatchString(line) && !isBlank(content[len(content)-1]) {
+				content = append(content, strings.TrimSpace(line))
+				i++
+				continue
+			}
+			break
+		}
+
+		it := item{}
+		if tm := taskItemPattern.FindStringSubmatch(content[0]); tm != nil {
+			it.task = true
+			it.checked = tm[1] != " "
+			content[0] = content[0][len(tm[0]):]
+		}
+		it.blocks = parseBlocks(content)
+		b.items = append(b.items, it)
+
+		// Blank lines between items keep the list going
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j < len(lines) && j > i && listPattern.MatchString(lines[j]) {
+			i = j
+		}
+	}
+
+	return b, i
+}
+
+// startsBlock reports whether a line interrupts a paragraph
+func startsBlock(line string) bool {
+	return fencePattern.MatchString(line) ||
+		headingPattern.MatchString(line) ||
+		rulePattern.MatchString(line) ||
+		quotePattern.MatchString(line) ||
+		interruptsWithList(line)
+}
+
+// interruptsWithList follows CommonMark: a list only interrupts a paragraph
+// if its first item has content and an ordered list starts at 1
+func interruptsWithList(line string) bool {
+	m := listPattern.FindStringSubmatch(line)
+	if m == nil || isBlank(line[len(m[0]):]) {
+		return false
+	}
+	return !isOrderedMarker(m[2]) || m[2][:len(m[2])-1] == "1"
+}
+
+func isOrderedMarker(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
@@ -0,0 +1,163 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ANSI escape sequences used by the terminal renderer
+const (
+	ansiReset     = "\033[0m"
+	ansiBold      = "\033[1m"
+	ansiDim       = "\033[2m"
+	ansiItalic    = "\033[3m"
+	ansiUnderline = "\033[4m"
+	ansiCyan      = "\033[36m"
+	ansiYellow    = "\033[33m"
+	ansiGreen     = "\033[32m"
+)
+
+// ToTerminal renders Markdown for a terminal. With color, ANSI escapes are
+// used for emphasis, code and task references; without it the output is
+// plain text that still shows the document's structure.
+func ToTerminal(src string, color bool) string {
+	r := &terminalRenderer{color: color}
+	var b strings.Builder
+	r.writeBlocks(&b, parse(src), "", false)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+type terminalRenderer struct {
+	color bool
+}
+
+// style wraps s in an ANSI sequence when color is enabled
+func (r *terminalRenderer) style(code string, s string) string {
+	if !r.color || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// writeBlocks renders blocks, starting every line with prefix (indentation
+// for list items, a bar for quotes). Blocks are separated by a blank line
+// unless tight is set.
+func (r *terminalRenderer) writeBlocks(b *strings.Builder, blocks []block, prefix string, tight bool) {
+	for i, bl := range blocks {
+		if i > 0 && !tight {
+			b.WriteString(strings.TrimRight(prefix, " ") + "\n")
+		}
+		r.writeBlock(b, bl, prefix)
+	}
+}
+
+func (r *terminalRenderer) writeBlock(b *strings.Builder, bl block, prefix string) {
+	switch bl.kind {
+	case blockParagraph:
+		writePrefixed(b, r.inline(parseInline(bl.text)), prefix, prefix)
+
+	case blockHeading:
+		text := plainText(parseInline(bl.text))
+		if bl.level == 1 {
+			writePrefixed(b, r.style(ansiBold+ansiUnderline, text), prefix, prefix)
+		} else {
+			writePrefixed(b, r.style(ansiBold, text), prefix, prefix)
+		}
+
+	case blockCode:
+		for _, line := range strings.Split(bl.text, "\n") {
+			b.WriteString(prefix + "    " + r.style(ansiCyan, line) + "\n")
+		}
+
+	case blockQuote:
+		r.writeBlocks(b, bl.children, prefix+r.style(ansiDim, "│")+" ", false)
+
+	case blockRule:
+		b.WriteString(prefix + r.style(ansiDim, strings.Repeat("─", 40)) + "\n")
+
+	case blockList:
+		for i, it := range bl.items {
+			marker := "•"
+			if bl.ordered {
+				marker = fmt.Sprintf("%d.", bl.start+i)
+			}
+			if it.task {
+				if it.checked {
+					marker += " " + r.style(ansiGreen, "[x]")
+				} else {
+					marker += " [ ]"
+				}
+			}
+
+			// Continuation lines line up with the text after the marker
+			indent := prefix + strings.Repeat(" ", visibleLength(marker)+1)
+			var item strings.Builder
+			r.writeBlocks(&item, it.blocks, indent, true)
+
+			text := strings.TrimPrefix(item.String(), indent)
+			if text == "" {
+				text = "\n"
+			}
+			b.WriteString(prefix + marker + " " + text)
+		}
+	}
+}
+
+// inline renders inline elements as styled text
+func (r *terminalRenderer) inline(inlines []inline) string {
+	var b strings.Builder
+	for _, in := range inlines {
+		switch in.kind {
+		case inlineText:
+			b.WriteString(in.text)
+		case inlineCode:
+			b.WriteString(r.style(ansiCyan, "`"+in.text+"`"))
+		case inlineStrong:
+			b.WriteString(r.style(ansiBold, r.inline(in.children)))
+		case inlineEmphasis:
+			b.WriteString(r.style(ansiItalic, r.inline(in.children)))
+		case inlineLink:
+			text := r.inline(in.children)
+			b.WriteString(r.style(ansiUnderline, text))
+			if plainText(in.children) != in.url {
+				b.WriteString(" (" + in.url + ")")
+			}
+		case inlineTaskRef:
+			b.WriteString(r.style(ansiYellow, fmt.Sprintf("#%d", in.taskID)))
+		case inlineBreak, inlineSoftBreak:
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// writePrefixed writes text line by line, the first line after first and the
+// rest after prefix
+func writePrefixed(b *strings.Builder, text string, first string, prefix string) {
+	for i, line := range strings.Split(text, "\n") {
+		if i == 0 {
+			b.WriteString(first + line + "\n")
+		} else {
+			b.WriteString(prefix + line + "\n")
+		}
+	}
+}
+
+// visibleLength is the display width of s, ignoring ANSI escapes
+func visibleLength(s string) int {
+	n := 0
+	inEscape := false
+	for _, c := range s {
+		switch {
+		case c == '\033':
+			inEscape = true
+		case inEscape:
+			if c == 'm' {
+				inEscape = false
+			}
+		default:
+			n++
+		}
+	}
+	return n
+}
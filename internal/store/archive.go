@@ -0,0 +1,121 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+// ArchiveSubDir holds the files of archived tasks. They are left out of the
+// index but can still be read and written by ID.
+const ArchiveSubDir = "archive"
+
+func (s *Store) archivePath(id int) string {
+	filename := fmt.Sprintf("%05d.json", id)
+	return filepath.Join(s.rootDir, TasksDir, ArchiveSubDir, filename)
+}
+
+// locateTask returns the path of a task's file: tasks/, or archive/ if the
+// task is archived
+func (s *Store) locateTask(id int) string {
+	path := s.taskPath(id)
+	if _, err := os.Stat(path); os.IsNotExist(err) && s.IsArchived(id) {
+		return s.archivePath(id)
+	}
+	return path
+}
+
+// IsArchived reports whether a task is in the archive
+func (s *Store) IsArchived(id int) bool {
+	_, err := os.Stat(s.archivePath(id))
+	return err == nil
+}
+
+// ArchiveTask moves a task file into the archive. The caller rebuilds the
+// index.
+func (s *Store) ArchiveTask(id int) error {
+	if err := os.MkdirAll(filepath.Join(s.rootDir, TasksDir, ArchiveSubDir), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := os.Rename(s.taskPath(id), s.archivePath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("task #%d %w", id, ErrNotFound)
+		}
+		return fmt.Errorf("failed to archive task: %w", err)
+	}
+	return nil
+}
+
+// UnarchiveTask moves a task file from the archive back to tasks/. The caller
+// rebuilds the index.
+func (s *Store) UnarchiveTask(id int) error {
+	if err := os.Rename(s.archivePath(id), s.taskPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("archived task #%d %w", id, ErrNotFound)
+		}
+		return fmt.Errorf("failed to unarchive task: %w", err)
+	}
+	return nil
+}
+
+// ForEachTask calls fn for every task, those in the index first and then the
+// archived ones, stopping at the first error fn returns. Tasks that can't be
+// read are skipped. Use it for lookups that must not miss a task just because
+// it was archived, such as finding links to a task.
+func (s *Store) ForEachTask(fn func(t *task.Task) error) error {
+	index, err := s.ReadIndex()
+	if err != nil {
+		return err
+	}
+	archived, err := s.ArchivedTaskIDs()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(index.Tasks)+len(archived))
+	for _, entry := range index.Tasks {
+		ids = append(ids, entry.ID)
+	}
+	ids = append(ids, archived...)
+
+	for _, id := range ids {
+		t, err := s.ReadTask(id)
+		if err != nil {
+			continue // Skip tasks we can't read
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchivedTaskIDs returns the IDs of archived tasks in ascending order
+func (s *Store) ArchivedTaskIDs() ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(s.rootDir, TasksDir, ArchiveSubDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue // Not a task file
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+func TestForEachTaskIncludesArchived(t *testing.T) {
+	s := New(t.TempDir())
+	if err := s.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	for id := 1; id <= 3; id++ {
+		if err := s.WriteTask(&task.Task{ID: id, Status: task.StatusDone, Title: "Task"}); err != nil {
+			t.Fatalf("WriteTask: %v", err)
+		}
+	}
+	if err := s.ArchiveTask(2); err != nil {
+		t.Fatalf("ArchiveTask: %v", err)
+	}
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex: %v", err)
+	}
+
+	var ids []int
+	err := s.ForEachTask(func(t *task.Task) error {
+		ids = append(ids, t.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachTask: %v", err)
+	}
+	if want := []int{1, 3, 2}; !slices.Equal(ids, want) {
+		t.Errorf("ForEachTask visited %v, want %v", ids, want)
+	}
+}
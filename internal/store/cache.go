@@ -0,0 +1,172 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+const (
+	CacheDir      = "cache"
+	GitignoreFile = ".gitignore"
+)
+
+// cachedIndex is the index as kept in index cache mode, with the stamps of
+// the task files it was built from
+type cachedIndex struct {
+	task.Index
+	Stamps map[int]TaskStamp `json:"stamps"`
+}
+
+// IndexCached reports whether the repository keeps its index in the cache
+// (see EnableIndexCache)
+func (s *Store) IndexCached() (bool, error) {
+	config, err := s.ReadConfig()
+	if err != nil {
+		return false, err
+	}
+	return config.IndexCache, nil
+}
+
+// IndexPath returns the path of the index file in the current mode
+func (s *Store) IndexPath() (string, error) {
+	cached, err := s.IndexCached()
+	if err != nil {
+		return "", err
+	}
+	if cached {
+		return s.cachedIndexPath(), nil
+	}
+	return filepath.Join(s.rootDir, TasksDir, IndexFile), nil
+}
+
+func (s *Store) cachedIndexPath() string {
+	return filepath.Join(s.rootDir, TasksDir, CacheDir, IndexFile)
+}
+
+// readCachedIndex returns the cached index, rebuilding it when it is
+// missing or any task file was added, removed or changed since it was built
+// (a pull or checkout changes task files without updating the cache)
+func (s *Store) readCachedIndex() (*task.Index, error) {
+	// Stamps are taken first, so a task file written during the rebuild
+	// makes the next read rebuild again rather than go unnoticed
+	stamps, err := s.TaskStamps()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(s.cachedIndexPath()); err == nil {
+		var cached cachedIndex
+		if json.Unmarshal(data, &cached) == nil && sameStamps(cached.Stamps, stamps) {
+			return &cached.Index, nil
+		}
+	}
+
+	index, err := s.buildIndex()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.writeCachedIndex(index, stamps); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func (s *Store) writeCachedIndex(index *task.Index, stamps map[int]TaskStamp) error {
+	if err := os.MkdirAll(filepath.Join(s.rootDir, TasksDir, CacheDir), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return s.writeJSONAtomic(s.cachedIndexPath(), cachedIndex{Index: *index, Stamps: stamps})
+}
+
+func sameStamps(a, b map[int]TaskStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, stamp := range a {
+		other, ok := b[id]
+		if !ok || other.Size != stamp.Size || !other.ModTime.Equal(stamp.ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// EnableIndexCache moves the index from .tasks/index.json to
+// .tasks/cache/index.json, which .tasks/.gitignore keeps out of git. The
+// caller is responsible for removing index.json from git.
+func (s *Store) EnableIndexCache() error {
+	config, err := s.ReadConfig()
+	if err != nil {
+		return err
+	}
+	config.IndexCache = true
+	if err := s.WriteConfig(config); err != nil {
+		return err
+	}
+
+	if err := s.ignoreCacheDir(); err != nil {
+		return err
+	}
+	if err := s.RebuildIndex(); err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(s.rootDir, TasksDir, IndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove index: %w", err)
+	}
+	return nil
+}
+
+// DisableIndexCache moves the index back to .tasks/index.json
+func (s *Store) DisableIndexCache() error {
+	config, err := s.ReadConfig()
+	if err != nil {
+		return err
+	}
+	config.IndexCache = false
+	if err := s.WriteConfig(config); err != nil {
+		return err
+	}
+
+	if err := s.RebuildIndex(); err != nil {
+		return err
+	}
+	// Only the index leaves the cache; the webhook log stays
+	err = os.Remove(s.cachedIndexPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cached index: %w", err)
+	}
+	return nil
+}
+
+// ignoreCacheDir adds the cache directory to .tasks/.gitignore
+func (s *Store) ignoreCacheDir() error {
+	path := filepath.Join(s.rootDir, TasksDir, GitignoreFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", GitignoreFile, err)
+	}
+
+	pattern := CacheDir + "/"
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += pattern + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", GitignoreFile, err)
+	}
+	return nil
+}
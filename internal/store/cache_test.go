@@ -0,0 +1,139 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+// newCachedStore returns an initialized store in index cache mode with one task
+func newCachedStore(t *testing.T) *Store {
+	t.Helper()
+	s := New(t.TempDir())
+	if err := s.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := s.WriteTask(&task.Task{ID: 1, Status: task.StatusBacklog, Title: "Original"}); err != nil {
+		t.Fatalf("WriteTask: %v", err)
+	}
+	if err := s.EnableIndexCache(); err != nil {
+		t.Fatalf("EnableIndexCache: %v", err)
+	}
+	return s
+}
+
+func TestCachedIndexNoticesChangedTaskFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, s *Store, path string)
+		want   []string // Titles in the index after the change
+	}{
+		{
+			name: "file rewritten, e.g. by a pull",
+			change: func(t *testing.T, s *Store, path string) {
+				writeFileAt(t, path, `{"id": 1, "status": "backlog", "title": "Pulled"}`, time.Now().Add(time.Second))
+			},
+			want: []string{"Pulled"},
+		},
+		{
+			name: "same size, new modification time",
+			change: func(t *testing.T, s *Store, path string) {
+				writeFileAt(t, path, `{"id": 1, "status": "backlog", "title": "Changed!"}`, time.Now().Add(time.Second))
+			},
+			want: []string{"Changed!"},
+		},
+		{
+			name: "file added",
+			change: func(t *testing.T, s *Store, path string) {
+				writeFileAt(t, filepath.Join(filepath.Dir(path), "00002.json"), `{"id": 2, "status": "backlog", "title": "Added"}`, time.Now())
+			},
+			want: []string{"Original", "Added"},
+		},
+		{
+			name: "file removed",
+			change: func(t *testing.T, s *Store, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newCachedStore(t)
+			if _, err := s.ReadIndex(); err != nil {
+				t.Fatalf("ReadIndex: %v", err)
+			}
+
+			tt.change(t, s, filepath.Join(s.Root(), TasksDir, TasksSubDir, "00001.json"))
+
+			index, err := s.ReadIndex()
+			if err != nil {
+				t.Fatalf("ReadIndex: %v", err)
+			}
+			assertTitles(t, index, tt.want)
+		})
+	}
+}
+
+// An index written with WriteIndex can't be matched to the task files it was
+// built from, so the cache must not treat it as current
+func TestCachedIndexWrittenDirectlyIsRebuilt(t *testing.T) {
+	s := newCachedStore(t)
+
+	stale := &task.Index{Tasks: []task.IndexEntry{{ID: 1, Status: task.StatusBacklog, Title: "Stale"}}}
+	if err := s.WriteIndex(stale); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+
+	index, err := s.ReadIndex()
+	if err != nil {
+		t.Fatalf("ReadIndex: %v", err)
+	}
+	assertTitles(t, index, []string{"Original"})
+}
+
+func TestDisableIndexCacheKeepsOtherCacheFiles(t *testing.T) {
+	s := newCachedStore(t)
+	if err := s.AppendWebhookDelivery(task.WebhookDelivery{ID: "1"}); err != nil {
+		t.Fatalf("AppendWebhookDelivery: %v", err)
+	}
+
+	if err := s.DisableIndexCache(); err != nil {
+		t.Fatalf("DisableIndexCache: %v", err)
+	}
+
+	if _, err := os.Stat(s.cachedIndexPath()); !os.IsNotExist(err) {
+		t.Errorf("cached index still exists: %v", err)
+	}
+	if deliveries, err := s.ReadWebhookDeliveries(); err != nil || len(deliveries) != 1 {
+		t.Errorf("webhook log = %v, %v; want it kept", deliveries, err)
+	}
+}
+
+func writeFileAt(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertTitles(t *testing.T, index *task.Index, want []string) {
+	t.Helper()
+	var titles []string
+	for _, e := range index.Tasks {
+		titles = append(titles, e.Title)
+	}
+	if !slices.Equal(titles, want) {
+		t.Fatalf("index titles %q, want %q", titles, want)
+	}
+}
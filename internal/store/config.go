@@ -0,0 +1,110 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+const (
+	ConfigFile     = "config.json"
+	WebhookLogFile = "webhooks.log"
+)
+
+// webhookLogMu serializes appends to the delivery log from concurrent deliveries
+var webhookLogMu sync.Mutex
+
+// ReadConfig reads config.json, returning an empty configuration if the file
+// doesn't exist
+func (s *Store) ReadConfig() (*task.Config, error) {
+	data, err := os.ReadFile(filepath.Join(s.rootDir, TasksDir, ConfigFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &task.Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config task.Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// WriteConfig writes config.json atomically
+func (s *Store) WriteConfig(config *task.Config) error {
+	return s.writeJSONAtomic(filepath.Join(s.rootDir, TasksDir, ConfigFile), config)
+}
+
+// webhookLogPath returns the path of the webhook delivery log. It is kept in
+// the cache directory because it is local history that must not be committed.
+func (s *Store) webhookLogPath() string {
+	return filepath.Join(s.rootDir, TasksDir, CacheDir, WebhookLogFile)
+}
+
+// AppendWebhookDelivery adds an entry to the webhook delivery log, a JSON
+// Lines file that is only ever appended to
+func (s *Store) AppendWebhookDelivery(delivery task.WebhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery: %w", err)
+	}
+
+	webhookLogMu.Lock()
+	defer webhookLogMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(s.rootDir, TasksDir, CacheDir), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// Repositories initialized before the cache directory was ignored by
+	// default get the ignore entry here, before the log is first written
+	if err := s.ignoreCacheDir(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.webhookLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open webhook log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write webhook log: %w", err)
+	}
+	return f.Close()
+}
+
+// ReadWebhookDeliveries returns the webhook delivery log, oldest first.
+// Unreadable lines are skipped.
+func (s *Store) ReadWebhookDeliveries() ([]task.WebhookDelivery, error) {
+	f, err := os.Open(s.webhookLogPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read webhook log: %w", err)
+	}
+	defer f.Close()
+
+	var deliveries []task.WebhookDelivery
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var d task.WebhookDelivery
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook log: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+const (
+	SessionsSubDir = "sessions"
+
+	// sessionTimeFormat prefixes session IDs so file names sort by start
+	// time. Milliseconds keep a session started right after another ended
+	// from getting the same ID.
+	sessionTimeFormat = "20060102T150405.000"
+)
+
+// NewSessionID returns a session ID for an agent starting a session at t
+func NewSessionID(agent string, t time.Time) string {
+	return t.UTC().Format(sessionTimeFormat) + "-" + encodeAgent(agent)
+}
+
+// encodeAgent makes an agent name safe for use in a file name. Letters,
+// digits and dots are kept and everything else becomes _XX (hex bytes), so
+// different names never share an encoding and the result never contains the
+// '-' that separates it from the time.
+func encodeAgent(agent string) string {
+	var b strings.Builder
+	for i := 0; i < len(agent); i++ {
+		c := agent[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// ReadSession reads a session file by ID
+func (s *Store) ReadSession(id string) (*task.Session, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("session %s %w", id, ErrNotFound)
+	}
+
+	data, err := os.ReadFile(s.sessionPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session %s %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var session task.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// CreateSession writes the file of a new session, failing with
+// ErrSessionExists rather than overwriting another session's journal
+func (s *Store) CreateSession(session *task.Session) error {
+	dir := filepath.Join(s.rootDir, TasksDir, SessionsSubDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	f, err := os.OpenFile(s.sessionPath(session.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("session %s %w", session.ID, ErrSessionExists)
+		}
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	f.Close()
+
+	return s.writeJSONAtomic(s.sessionPath(session.ID), session)
+}
+
+// WriteSession writes a session file atomically
+func (s *Store) WriteSession(session *task.Session) error {
+	dir := filepath.Join(s.rootDir, TasksDir, SessionsSubDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	return s.writeJSONAtomic(s.sessionPath(session.ID), session)
+}
+
+// ListSessions returns all sessions, oldest first
+func (s *Store) ListSessions() ([]task.Session, error) {
+	ids, err := s.sessionIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []task.Session
+	for _, id := range ids {
+		session, err := s.ReadSession(id)
+		if err != nil {
+			continue // Skip sessions we can't read
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, nil
+}
+
+// CurrentSession returns the open session for an agent, or nil if the agent
+// has no open session
+func (s *Store) CurrentSession(agent string) (*task.Session, error) {
+	ids, err := s.sessionIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the agent's most recent session can still be open. The name in
+	// the ID only narrows the search; the session file has the real name.
+	encoded := encodeAgent(agent)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if ids[i][strings.LastIndex(ids[i], "-")+1:] != encoded {
+			continue
+		}
+
+		session, err := s.ReadSession(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		if session.Agent != agent {
+			continue
+		}
+		if session.IsOpen() {
+			return session, nil
+		}
+		return nil, nil
+	}
+
+	return nil, nil
+}
+
+// sessionIDs returns all session IDs sorted by start time
+func (s *Store) sessionIDs() ([]string, error) {
+	dir := filepath.Join(s.rootDir, TasksDir, SessionsSubDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// sessionPath returns the file path for a session ID
+func (s *Store) sessionPath(id string) string {
+	return filepath.Join(s.rootDir, TasksDir, SessionsSubDir, id+".json")
+}
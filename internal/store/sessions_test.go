@@ -0,0 +1,75 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onuse/tasks/internal/task"
+)
+
+func TestEncodeAgent(t *testing.T) {
+	tests := []struct {
+		agent string
+		want  string
+	}{
+		{"claude", "claude"},
+		{"gpt-4.1", "gpt_2d4.1"},
+		{"a_b", "a_5fb"},
+		{"a b/c", "a_20b_2fc"},
+	}
+	for _, tt := range tests {
+		if got := encodeAgent(tt.agent); got != tt.want {
+			t.Errorf("encodeAgent(%q) = %q, want %q", tt.agent, got, tt.want)
+		}
+	}
+
+	if encodeAgent("a-b") == encodeAgent("a_b") {
+		t.Errorf("a-b and a_b share the encoding %q", encodeAgent("a-b"))
+	}
+}
+
+func TestNewSessionIDSubSecond(t *testing.T) {
+	start := time.Date(2025, 11, 3, 10, 30, 0, 0, time.UTC)
+	a := NewSessionID("claude", start)
+	b := NewSessionID("claude", start.Add(5*time.Millisecond))
+	if a == b {
+		t.Fatalf("sessions 5ms apart share the ID %s", a)
+	}
+	if a > b {
+		t.Errorf("IDs don't sort by start time: %s > %s", a, b)
+	}
+}
+
+func TestCreateSessionRefusesOverwrite(t *testing.T) {
+	s := New(t.TempDir())
+	session := &task.Session{ID: NewSessionID("claude", time.Now()), Agent: "claude", Started: time.Now()}
+
+	if err := s.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.CreateSession(session); !errors.Is(err, ErrSessionExists) {
+		t.Fatalf("second CreateSession = %v, want ErrSessionExists", err)
+	}
+}
+
+func TestCurrentSessionMatchesAgentExactly(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+	for i, agent := range []string{"a-b", "a_b"} {
+		session := &task.Session{ID: NewSessionID(agent, now.Add(time.Duration(i)*time.Millisecond)), Agent: agent, Started: now}
+		if err := s.CreateSession(session); err != nil {
+			t.Fatalf("CreateSession(%s): %v", agent, err)
+		}
+	}
+
+	for _, agent := range []string{"a-b", "a_b"} {
+		session, err := s.CurrentSession(agent)
+		if err != nil {
+			t.Fatalf("CurrentSession(%s): %v", agent, err)
+		}
+		if session == nil || session.Agent != agent {
+			t.Errorf("CurrentSession(%s) = %+v", agent, session)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/onuse/tasks/internal/task"
@@ -18,6 +21,14 @@ const (
 	IndexFile    = "index.json"
 )
 
+// Sentinel errors returned by the store. Callers match them with errors.Is.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrNotInitialized     = errors.New("not in a task repository (no .tasks/ directory found)")
+	ErrAlreadyInitialized = errors.New(".tasks/ directory already exists")
+	ErrSessionExists      = errors.New("already exists")
+)
+
 // Store handles all file I/O operations
 type Store struct {
 	rootDir string
@@ -28,6 +39,11 @@ func New(rootDir string) *Store {
 	return &Store{rootDir: rootDir}
 }
 
+// Root returns the directory that contains .tasks/
+func (s *Store) Root() string {
+	return s.rootDir
+}
+
 // FindTaskRoot walks up the directory tree to find .tasks directory
 func FindTaskRoot() (string, error) {
 	dir, err := os.Getwd()
@@ -43,7 +59,7 @@ func FindTaskRoot() (string, error) {
 
 		parent := filepath.Dir(dir)
 		if parent == dir {
-			return "", fmt.Errorf("not in a task repository (no .tasks/ directory found)")
+			return "", ErrNotInitialized
 		}
 		dir = parent
 	}
@@ -55,7 +71,7 @@ func (s *Store) Init() error {
 
 	// Check if already exists
 	if _, err := os.Stat(tasksPath); err == nil {
-		return fmt.Errorf(".tasks/ directory already exists")
+		return ErrAlreadyInitialized
 	}
 
 	// Create directories
@@ -73,6 +89,11 @@ func (s *Store) Init() error {
 		return err
 	}
 
+	// Keep local-only files, like the webhook delivery log, out of git
+	if err := s.ignoreCacheDir(); err != nil {
+		return err
+	}
+
 	// Create empty index
 	index := task.Index{
 		Tasks:   []task.IndexEntry{},
@@ -107,8 +128,18 @@ func (s *Store) WriteManifest(manifest *task.Manifest) error {
 	return s.writeJSONAtomic(path, manifest)
 }
 
-// ReadIndex reads the index.json file
+// ReadIndex reads the index.json file. In index cache mode it reads the
+// cached index instead, rebuilding it if task files changed since it was
+// written.
 func (s *Store) ReadIndex() (*task.Index, error) {
+	cached, err := s.IndexCached()
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		return s.readCachedIndex()
+	}
+
 	path := filepath.Join(s.rootDir, TasksDir, IndexFile)
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -120,22 +151,37 @@ func (s *Store) ReadIndex() (*task.Index, error) {
 		return nil, fmt.Errorf("failed to parse index: %w", err)
 	}
 
+	// A merge left the index to be rebuilt from the merged task files
+	if index.Stale {
+		return s.rebuildIndex()
+	}
+
 	return &index, nil
 }
 
-// WriteIndex writes the index.json file atomically
+// WriteIndex writes the index.json file atomically, or the cached index in
+// index cache mode. The cache can't tell which task files an index passed in
+// was built from, so it is rebuilt on the next read; RebuildIndex keeps it.
 func (s *Store) WriteIndex(index *task.Index) error {
+	cached, err := s.IndexCached()
+	if err != nil {
+		return err
+	}
+	if cached {
+		return s.writeCachedIndex(index, nil)
+	}
+
 	path := filepath.Join(s.rootDir, TasksDir, IndexFile)
 	return s.writeJSONAtomic(path, index)
 }
 
-// ReadTask reads a task file by ID
+// ReadTask reads a task file by ID, archived or not
 func (s *Store) ReadTask(id int) (*task.Task, error) {
-	path := s.taskPath(id)
+	path := s.locateTask(id)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("task #%d not found", id)
+			return nil, fmt.Errorf("task #%d %w", id, ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to read task: %w", err)
 	}
@@ -148,18 +194,57 @@ func (s *Store) ReadTask(id int) (*task.Task, error) {
 	return &t, nil
 }
 
-// WriteTask writes a task file atomically
+// WriteTask writes a task file atomically, in the archive if the task is
+// archived
 func (s *Store) WriteTask(t *task.Task) error {
-	path := s.taskPath(t.ID)
+	path := s.locateTask(t.ID)
 	return s.writeJSONAtomic(path, t)
 }
 
 // RebuildIndex rebuilds the index from all task files
 func (s *Store) RebuildIndex() error {
+	_, err := s.rebuildIndex()
+	return err
+}
+
+// rebuildIndex builds the index from the task files and writes it. In index
+// cache mode the stamps are taken before the build, so a task file written
+// during it makes the next read rebuild again rather than go unnoticed.
+func (s *Store) rebuildIndex() (*task.Index, error) {
+	cached, err := s.IndexCached()
+	if err != nil {
+		return nil, err
+	}
+
+	var stamps map[int]TaskStamp
+	if cached {
+		if stamps, err = s.TaskStamps(); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := s.buildIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	if cached {
+		err = s.writeCachedIndex(index, stamps)
+	} else {
+		err = s.writeJSONAtomic(filepath.Join(s.rootDir, TasksDir, IndexFile), index)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// buildIndex builds the index from the task files
+func (s *Store) buildIndex() (*task.Index, error) {
 	tasksDir := filepath.Join(s.rootDir, TasksDir, TasksSubDir)
 	entries, err := os.ReadDir(tasksDir)
 	if err != nil {
-		return fmt.Errorf("failed to read tasks directory: %w", err)
+		return nil, fmt.Errorf("failed to read tasks directory: %w", err)
 	}
 
 	var indexEntries []task.IndexEntry
@@ -180,11 +265,12 @@ func (s *Store) RebuildIndex() error {
 		}
 
 		indexEntries = append(indexEntries, task.IndexEntry{
-			ID:      t.ID,
-			Status:  t.Status,
-			Title:   t.Title,
-			Created: t.Created,
-			Updated: t.Updated,
+			ID:        t.ID,
+			Status:    t.Status,
+			Title:     t.Title,
+			Created:   t.Created,
+			Updated:   t.Updated,
+			Completed: t.Completed,
 		})
 	}
 
@@ -193,12 +279,46 @@ func (s *Store) RebuildIndex() error {
 		return indexEntries[i].ID < indexEntries[j].ID
 	})
 
-	index := task.Index{
+	return &task.Index{
 		Tasks:   indexEntries,
 		Updated: time.Now(),
+	}, nil
+}
+
+// TaskStamp identifies the version of a task file on disk
+type TaskStamp struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+}
+
+// TaskStamps returns the modification time and size of every task file, keyed by task ID
+func (s *Store) TaskStamps() (map[int]TaskStamp, error) {
+	tasksDir := filepath.Join(s.rootDir, TasksDir, TasksSubDir)
+	entries, err := os.ReadDir(tasksDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks directory: %w", err)
+	}
+
+	stamps := make(map[int]TaskStamp, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue // Not a task file
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed while listing
+		}
+
+		stamps[id] = TaskStamp{ModTime: info.ModTime(), Size: info.Size()}
 	}
 
-	return s.WriteIndex(&index)
+	return stamps, nil
 }
 
 // taskPath returns the file path for a task ID
@@ -0,0 +1,51 @@
+package task
+
+import "time"
+
+// Config is the repository configuration stored in .tasks/config.json
+type Config struct {
+	Webhooks   []Webhook `json:"webhooks,omitempty"`
+	IndexCache bool      `json:"index_cache,omitempty"` // Keep the index in the gitignored .tasks/cache/ instead of .tasks/index.json
+	Workflow   *Workflow `json:"workflow,omitempty"`    // Custom statuses and transitions; nil uses DefaultWorkflow
+
+	WIPLimits map[Status]WIPLimit `json:"wip_limits,omitempty"` // Caps on the number of tasks per status
+}
+
+// WIPLimit caps how many tasks can be in a status at once. Zero means no limit.
+type WIPLimit struct {
+	Total    int `json:"total,omitempty"`     // Across the repository
+	PerAgent int `json:"per_agent,omitempty"` // Per agent, counting the tasks each agent moved into the status
+}
+
+// Webhook is an HTTP endpoint that is sent task events
+type Webhook struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // Event types to deliver; empty or "*" means all
+	Secret string   `json:"secret,omitempty"` // HMAC-SHA256 signing key, or "env:NAME" to read it from the environment
+}
+
+// WantsEvent reports whether the webhook subscribes to an event type
+func (w Webhook) WantsEvent(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an entry in the webhook delivery log
+type WebhookDelivery struct {
+	ID        string    `json:"id"` // Event ID, also sent as X-Task-Delivery
+	Timestamp time.Time `json:"timestamp"`
+	Event     string    `json:"event"`
+	TaskID    int       `json:"task_id,omitempty"`
+	URL       string    `json:"url"`
+	Attempts  int       `json:"attempts"`
+	Status    int       `json:"status,omitempty"` // HTTP status of the last attempt
+	Error     string    `json:"error,omitempty"`
+	Delivered bool      `json:"delivered"`
+}
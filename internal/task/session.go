@@ -0,0 +1,72 @@
+package task
+
+import (
+	"sort"
+	"time"
+)
+
+// Session actions recorded in the journal
+const (
+	ActionCreated     = "created"
+	ActionStatus      = "status"
+	ActionTitle       = "title"
+	ActionDescription = "description"
+	ActionDue         = "due"
+	ActionNote        = "note"
+	ActionLink        = "link"
+	ActionUnlink      = "unlink"
+	ActionTag         = "tag"
+	ActionUntag       = "untag"
+	ActionMerge       = "merge"
+	ActionImport      = "import"
+	ActionArchive     = "archive"
+	ActionUnarchive   = "unarchive"
+)
+
+// SessionEvent is a single change made to a task during a session
+type SessionEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	TaskID    int       `json:"task_id"`
+	Action    string    `json:"action"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+}
+
+// Session groups the changes an agent made during one run
+type Session struct {
+	ID      string         `json:"id"`
+	Agent   string         `json:"agent"`
+	Started time.Time      `json:"started"`
+	Ended   *time.Time     `json:"ended,omitempty"`
+	Summary string         `json:"summary,omitempty"`
+	Tasks   []int          `json:"tasks"`  // IDs of tasks touched, sorted
+	Events  []SessionEvent `json:"events"` // Changes in the order they were made
+}
+
+// IsOpen reports whether the session has not been ended yet
+func (s *Session) IsOpen() bool {
+	return s.Ended == nil
+}
+
+// AddEvent appends an event and records the task as touched
+func (s *Session) AddEvent(event SessionEvent) {
+	s.Events = append(s.Events, event)
+
+	for _, id := range s.Tasks {
+		if id == event.TaskID {
+			return
+		}
+	}
+	s.Tasks = append(s.Tasks, event.TaskID)
+	sort.Ints(s.Tasks)
+}
+
+// Touched reports whether the session changed the given task
+func (s *Session) Touched(taskID int) bool {
+	for _, id := range s.Tasks {
+		if id == taskID {
+			return true
+		}
+	}
+	return false
+}
@@ -17,9 +17,14 @@ const (
 	StatusLabel     Status = "label"
 )
 
-// ValidStatuses returns all valid status values
+// ValidStatuses returns all valid status values: the statuses of the current
+// workflow in board order, then label
 func ValidStatuses() []Status {
-	return []Status{StatusBacklog, StatusNext, StatusActive, StatusBlocked, StatusDone, StatusCancelled, StatusLabel}
+	statuses := make([]Status, 0, len(workflow.Statuses)+1)
+	for _, s := range workflow.Statuses {
+		statuses = append(statuses, s.Name)
+	}
+	return append(statuses, StatusLabel)
 }
 
 // IsValidStatus checks if a status string is valid
@@ -38,6 +43,31 @@ type Note struct {
 	Timestamp time.Time `json:"timestamp"`
 	Author    string    `json:"author"`
 	Text      string    `json:"text"`
+	Kind      string    `json:"kind,omitempty"`    // Optional note kind: "decision", "question", "blocker", "progress"
+	Session   string    `json:"session,omitempty"` // ID of the agent session the note was written in
+}
+
+// Note kinds
+const (
+	NoteKindDecision = "decision"
+	NoteKindQuestion = "question"
+	NoteKindBlocker  = "blocker"
+	NoteKindProgress = "progress"
+)
+
+// ValidNoteKinds returns all valid note kinds
+func ValidNoteKinds() []string {
+	return []string{NoteKindDecision, NoteKindQuestion, NoteKindBlocker, NoteKindProgress}
+}
+
+// IsValidNoteKind checks if a note kind string is valid
+func IsValidNoteKind(kind string) bool {
+	for _, valid := range ValidNoteKinds() {
+		if kind == valid {
+			return true
+		}
+	}
+	return false
 }
 
 // TaskLink represents a relationship between tasks
@@ -59,31 +89,63 @@ const (
 
 // Task represents a single task
 type Task struct {
-	ID           int        `json:"id"`
-	Created      time.Time  `json:"created"`
-	Updated      time.Time  `json:"updated"`
-	Status       Status     `json:"status"`
-	Title        string     `json:"title"`
-	Description  string     `json:"description"`
-	Notes        []Note     `json:"notes"`
-	Links        []TaskLink `json:"links"`
-	Dependencies []int      `json:"dependencies"` // Deprecated: kept for backward compatibility, use Links instead
-	Tags         []string   `json:"tags"`
+	ID           int          `json:"id"`
+	Created      time.Time    `json:"created"`
+	Updated      time.Time    `json:"updated"`
+	Status       Status       `json:"status"`
+	StatusBy     string       `json:"status_by,omitempty"`    // Agent that moved the task into its current status
+	StatusSince  *time.Time   `json:"status_since,omitempty"` // When the task moved into its current status
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	Notes        []Note       `json:"notes"`
+	Links        []TaskLink   `json:"links"`
+	Dependencies []int        `json:"dependencies"` // Deprecated: kept for backward compatibility, use Links instead
+	Tags         []string     `json:"tags"`
+	Due          string       `json:"due,omitempty"`       // Due date, DueDateFormat
+	Completed    *time.Time   `json:"completed,omitempty"` // When the task last moved to a closed status
+	External     *ExternalRef `json:"external,omitempty"`  // Issue the task was imported from
+	Code         *CodeRef     `json:"code,omitempty"`      // Source comment the task was scanned from
+}
+
+// ExternalRef identifies an issue in another tracker
+type ExternalRef struct {
+	Source string `json:"source"` // "github" or "gitlab"
+	ID     string `json:"id"`     // e.g. "owner/repo#12"
+	URL    string `json:"url,omitempty"`
+}
+
+// CodeRef locates a TODO-style comment in the repository
+type CodeRef struct {
+	File        string `json:"file"` // Slash-separated, relative to the repository root
+	Line        int    `json:"line"`
+	Marker      string `json:"marker"`      // e.g. "TODO", "FIXME"
+	Fingerprint string `json:"fingerprint"` // Stable across line moves, see "task scan"
+}
+
+// DueDateFormat is the layout of Task.Due (a calendar date, no time zone)
+const DueDateFormat = "2006-01-02"
+
+// IsValidDueDate checks if a string is a valid due date
+func IsValidDueDate(s string) bool {
+	_, err := time.Parse(DueDateFormat, s)
+	return err == nil
 }
 
 // IndexEntry represents a minimal task entry for fast queries
 type IndexEntry struct {
-	ID      int       `json:"id"`
-	Status  Status    `json:"status"`
-	Title   string    `json:"title"`
-	Created time.Time `json:"created"`
-	Updated time.Time `json:"updated"`
+	ID        int        `json:"id"`
+	Status    Status     `json:"status"`
+	Title     string     `json:"title"`
+	Created   time.Time  `json:"created"`
+	Updated   time.Time  `json:"updated"`
+	Completed *time.Time `json:"completed,omitempty"`
 }
 
 // Index represents the cached index of all tasks
 type Index struct {
 	Tasks   []IndexEntry `json:"tasks"`
 	Updated time.Time    `json:"updated"`
+	Stale   bool         `json:"stale,omitempty"` // Set by the merge driver: rebuild from the task files before use
 }
 
 // Manifest represents the manifest.json file
@@ -133,6 +195,17 @@ func (t *Task) GetLinks(linkType string) []TaskLink {
 	return result
 }
 
+// NotesOfKind returns all notes of a specific kind
+func (t *Task) NotesOfKind(kind string) []Note {
+	var result []Note
+	for _, note := range t.Notes {
+		if note.Kind == kind {
+			result = append(result, note)
+		}
+	}
+	return result
+}
+
 // HasLink checks if a link exists
 func (t *Task) HasLink(targetID int, linkType string) bool {
 	for _, link := range t.Links {
@@ -142,3 +215,21 @@ func (t *Task) HasLink(targetID int, linkType string) bool {
 	}
 	return false
 }
+
+// Clone returns a copy of the task that shares no slices with the original
+func (t *Task) Clone() *Task {
+	c := *t
+	c.Notes = append([]Note(nil), t.Notes...)
+	c.Links = append([]TaskLink(nil), t.Links...)
+	c.Dependencies = append([]int(nil), t.Dependencies...)
+	c.Tags = append([]string(nil), t.Tags...)
+	if t.External != nil {
+		external := *t.External
+		c.External = &external
+	}
+	if t.Code != nil {
+		code := *t.Code
+		c.Code = &code
+	}
+	return &c
+}
@@ -0,0 +1,151 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MergeConflict is a field both sides of a merge changed to different values
+type MergeConflict struct {
+	Field     string
+	Kept      any
+	Discarded any
+}
+
+// mergeField reads and copies one scalar field of a task
+type mergeField struct {
+	name string
+	get  func(t *Task) any
+	take func(dst, src *Task)
+}
+
+var mergeFields = []mergeField{
+	{"title", func(t *Task) any { return t.Title }, func(d, s *Task) { d.Title = s.Title }},
+	{"description", func(t *Task) any { return t.Description }, func(d, s *Task) { d.Description = s.Description }},
+	{"status", func(t *Task) any { return t.Status }, func(d, s *Task) { d.Status = s.Status }},
+	{"status_by", func(t *Task) any { return t.StatusBy }, func(d, s *Task) { d.StatusBy = s.StatusBy }},
+	{"status_since", func(t *Task) any { return t.StatusSince }, func(d, s *Task) { d.StatusSince = s.StatusSince }},
+	{"due", func(t *Task) any { return t.Due }, func(d, s *Task) { d.Due = s.Due }},
+	{"completed", func(t *Task) any { return t.Completed }, func(d, s *Task) { d.Completed = s.Completed }},
+	{"external", func(t *Task) any { return t.External }, func(d, s *Task) { d.External = s.External }},
+	{"code", func(t *Task) any { return t.Code }, func(d, s *Task) { d.Code = s.Code }},
+}
+
+// MergeTask merges two versions of a task changed from a common base; base is
+// nil if both sides added the task. A field changed on one side takes that
+// side's value. A field changed on both sides to different values takes the
+// value of the more recently updated side, and is reported as a conflict.
+// Notes are combined; links, dependencies and tags keep additions from both
+// sides and drop what either side removed.
+func MergeTask(base, ours, theirs *Task) (*Task, []MergeConflict) {
+	merged := ours.Clone()
+	theirsNewer := theirs.Updated.After(ours.Updated)
+
+	var conflicts []MergeConflict
+	for _, f := range mergeFields {
+		o, t := f.get(ours), f.get(theirs)
+		if jsonEqual(o, t) {
+			continue
+		}
+		if base != nil && jsonEqual(f.get(base), o) {
+			f.take(merged, theirs)
+			continue
+		}
+		if base != nil && jsonEqual(f.get(base), t) {
+			continue
+		}
+
+		if theirsNewer {
+			f.take(merged, theirs)
+			conflicts = append(conflicts, MergeConflict{Field: f.name, Kept: t, Discarded: o})
+		} else {
+			conflicts = append(conflicts, MergeConflict{Field: f.name, Kept: o, Discarded: t})
+		}
+	}
+
+	if theirsNewer {
+		merged.Updated = theirs.Updated
+	}
+
+	var baseLinks []TaskLink
+	var baseDeps []int
+	var baseTags []string
+	if base != nil {
+		baseLinks, baseDeps, baseTags = base.Links, base.Dependencies, base.Tags
+	}
+	merged.Notes = mergeNotes(ours.Notes, theirs.Notes)
+	merged.Links = mergeSet(baseLinks, ours.Links, theirs.Links, func(l TaskLink) string {
+		return fmt.Sprintf("%d\x00%s\x00%s", l.TargetID, l.Type, l.Label)
+	})
+	merged.Dependencies = mergeSet(baseDeps, ours.Dependencies, theirs.Dependencies, strconv.Itoa)
+	merged.Tags = mergeSet(baseTags, ours.Tags, theirs.Tags, func(s string) string { return s })
+
+	return merged, conflicts
+}
+
+// mergeNotes combines the notes of both sides, oldest first. Notes can't be
+// removed, so nothing is dropped.
+func mergeNotes(ours, theirs []Note) []Note {
+	notes := append([]Note{}, ours...)
+	seen := map[string]bool{}
+	for _, n := range ours {
+		seen[noteKey(n)] = true
+	}
+	for _, n := range theirs {
+		if !seen[noteKey(n)] {
+			seen[noteKey(n)] = true
+			notes = append(notes, n)
+		}
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].Timestamp.Before(notes[j].Timestamp)
+	})
+	return notes
+}
+
+func noteKey(n Note) string {
+	return n.Timestamp.UTC().Format(time.RFC3339Nano) + "\x00" + n.Author + "\x00" + n.Text
+}
+
+// mergeSet keeps the items both sides have, and the items either side added
+// since base. Items one side removed are dropped.
+func mergeSet[T any](base, ours, theirs []T, key func(T) string) []T {
+	inBase := map[string]bool{}
+	for _, x := range base {
+		inBase[key(x)] = true
+	}
+	inTheirs := map[string]bool{}
+	for _, x := range theirs {
+		inTheirs[key(x)] = true
+	}
+
+	merged := []T{}
+	inOurs := map[string]bool{}
+	for _, x := range ours {
+		k := key(x)
+		inOurs[k] = true
+		if inTheirs[k] || !inBase[k] {
+			merged = append(merged, x)
+		}
+	}
+	for _, x := range theirs {
+		k := key(x)
+		if !inOurs[k] && !inBase[k] {
+			inOurs[k] = true
+			merged = append(merged, x)
+		}
+	}
+	return merged
+}
+
+// jsonEqual compares values by their JSON encoding, which is what ends up
+// in the task file
+func jsonEqual(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
@@ -0,0 +1,137 @@
+package task
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergeTask(t *testing.T) {
+	t0 := time.Date(2025, 11, 3, 10, 0, 0, 0, time.UTC)
+	t1, t2 := t0.Add(time.Hour), t0.Add(2*time.Hour)
+
+	base := &Task{ID: 1, Created: t0, Updated: t0, Status: StatusBacklog, Title: "Base", Tags: []string{"a"}}
+	edit := func(updated time.Time, change func(*Task)) *Task {
+		c := base.Clone()
+		c.Updated = updated
+		change(c)
+		return c
+	}
+
+	tests := []struct {
+		name          string
+		base          *Task
+		ours, theirs  *Task
+		wantTitle     string
+		wantStatus    Status
+		wantTags      []string
+		wantConflicts []string
+		wantUpdated   time.Time
+	}{
+		{
+			name:        "each side changes a different field",
+			base:        base,
+			ours:        edit(t1, func(c *Task) { c.Title = "Ours" }),
+			theirs:      edit(t2, func(c *Task) { c.Status = StatusActive }),
+			wantTitle:   "Ours",
+			wantStatus:  StatusActive,
+			wantTags:    []string{"a"},
+			wantUpdated: t2,
+		},
+		{
+			name:          "both change a field, theirs newer",
+			base:          base,
+			ours:          edit(t1, func(c *Task) { c.Title = "Ours" }),
+			theirs:        edit(t2, func(c *Task) { c.Title = "Theirs" }),
+			wantTitle:     "Theirs",
+			wantStatus:    StatusBacklog,
+			wantTags:      []string{"a"},
+			wantConflicts: []string{"title"},
+			wantUpdated:   t2,
+		},
+		{
+			name:          "both change a field, ours newer",
+			base:          base,
+			ours:          edit(t2, func(c *Task) { c.Title = "Ours" }),
+			theirs:        edit(t1, func(c *Task) { c.Title = "Theirs" }),
+			wantTitle:     "Ours",
+			wantStatus:    StatusBacklog,
+			wantTags:      []string{"a"},
+			wantConflicts: []string{"title"},
+			wantUpdated:   t2,
+		},
+		{
+			name:        "both make the same change",
+			base:        base,
+			ours:        edit(t1, func(c *Task) { c.Status = StatusDone }),
+			theirs:      edit(t2, func(c *Task) { c.Status = StatusDone }),
+			wantTitle:   "Base",
+			wantStatus:  StatusDone,
+			wantTags:    []string{"a"},
+			wantUpdated: t2,
+		},
+		{
+			name:        "tags added on both sides and removed on one",
+			base:        base,
+			ours:        edit(t1, func(c *Task) { c.Tags = []string{"b"} }),
+			theirs:      edit(t2, func(c *Task) { c.Tags = []string{"a", "c"} }),
+			wantTitle:   "Base",
+			wantStatus:  StatusBacklog,
+			wantTags:    []string{"b", "c"},
+			wantUpdated: t2,
+		},
+		{
+			name:          "no base: differing fields conflict",
+			base:          nil,
+			ours:          edit(t2, func(c *Task) { c.Title = "Ours" }),
+			theirs:        edit(t1, func(c *Task) { c.Title = "Theirs" }),
+			wantTitle:     "Ours",
+			wantStatus:    StatusBacklog,
+			wantTags:      []string{"a"},
+			wantConflicts: []string{"title"},
+			wantUpdated:   t2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts := MergeTask(tt.base, tt.ours, tt.theirs)
+
+			if merged.Title != tt.wantTitle || merged.Status != tt.wantStatus {
+				t.Errorf("merged title %q status %s, want %q %s", merged.Title, merged.Status, tt.wantTitle, tt.wantStatus)
+			}
+			if !slices.Equal(merged.Tags, tt.wantTags) {
+				t.Errorf("merged tags %v, want %v", merged.Tags, tt.wantTags)
+			}
+			if !merged.Updated.Equal(tt.wantUpdated) {
+				t.Errorf("merged updated %v, want %v", merged.Updated, tt.wantUpdated)
+			}
+
+			var fields []string
+			for _, c := range conflicts {
+				fields = append(fields, c.Field)
+			}
+			if !slices.Equal(fields, tt.wantConflicts) {
+				t.Errorf("conflicts on %v, want %v", fields, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestMergeTaskCombinesNotes(t *testing.T) {
+	t0 := time.Date(2025, 11, 3, 10, 0, 0, 0, time.UTC)
+	shared := Note{Timestamp: t0, Author: "ann", Text: "shared"}
+	ours := &Task{ID: 1, Updated: t0, Notes: []Note{shared, {Timestamp: t0.Add(2 * time.Minute), Author: "ann", Text: "ours"}}}
+	theirs := &Task{ID: 1, Updated: t0, Notes: []Note{shared, {Timestamp: t0.Add(time.Minute), Author: "bob", Text: "theirs"}}}
+	base := &Task{ID: 1, Updated: t0, Notes: []Note{shared}}
+
+	merged, _ := MergeTask(base, ours, theirs)
+
+	var texts []string
+	for _, n := range merged.Notes {
+		texts = append(texts, n.Text)
+	}
+	if want := []string{"shared", "theirs", "ours"}; !slices.Equal(texts, want) {
+		t.Errorf("notes %v, want %v", texts, want)
+	}
+}
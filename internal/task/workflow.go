@@ -0,0 +1,140 @@
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Status categories: open statuses are work still to do, closed statuses are
+// finished work
+const (
+	CategoryOpen   = "open"
+	CategoryClosed = "closed"
+)
+
+// WorkflowStatus is a status tasks can have, shown as a board column
+type WorkflowStatus struct {
+	Name     Status `json:"name"`
+	Color    string `json:"color,omitempty"` // CSS color on the board; the built-in statuses have one in style.css
+	Category string `json:"category"`        // "open" or "closed"
+}
+
+// Workflow defines the statuses of a repository in board order, and
+// optionally which status changes are allowed
+type Workflow struct {
+	Statuses    []WorkflowStatus    `json:"statuses"`
+	Transitions map[Status][]Status `json:"transitions,omitempty"` // Statuses a task may move to from each status; a status without an entry may move to any status
+}
+
+// requiredStatuses are part of every workflow: new tasks start in backlog,
+// and commands close tasks as done or cancelled
+var requiredStatuses = []Status{StatusBacklog, StatusDone, StatusCancelled}
+
+// statusNamePattern keeps status names usable as command line values and
+// CSS class names
+var statusNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// DefaultWorkflow returns the workflow used when config.json doesn't define one
+func DefaultWorkflow() *Workflow {
+	return &Workflow{
+		Statuses: []WorkflowStatus{
+			{Name: StatusBacklog, Category: CategoryOpen},
+			{Name: StatusNext, Category: CategoryOpen},
+			{Name: StatusActive, Category: CategoryOpen},
+			{Name: StatusBlocked, Category: CategoryOpen},
+			{Name: StatusDone, Category: CategoryClosed},
+			{Name: StatusCancelled, Category: CategoryClosed},
+		},
+	}
+}
+
+// workflow is the workflow of the repository being worked on
+var workflow = DefaultWorkflow()
+
+// SetWorkflow sets the workflow that ValidStatuses, IsValidStatus and
+// IsClosedStatus follow. Nil restores the default workflow.
+func SetWorkflow(w *Workflow) {
+	if w == nil {
+		w = DefaultWorkflow()
+	}
+	workflow = w
+}
+
+// CurrentWorkflow returns the workflow set by SetWorkflow
+func CurrentWorkflow() *Workflow {
+	return workflow
+}
+
+// Validate checks that statuses are unique, well-named and categorized, that
+// the required statuses are present, and that transitions only name known
+// statuses
+func (w *Workflow) Validate() error {
+	if len(w.Statuses) == 0 {
+		return fmt.Errorf("workflow has no statuses")
+	}
+
+	seen := map[Status]bool{}
+	for _, s := range w.Statuses {
+		switch {
+		case !statusNamePattern.MatchString(string(s.Name)):
+			return fmt.Errorf("invalid status name '%s' (lowercase letters, digits, '-' and '_')", s.Name)
+		case s.Name == StatusLabel || s.Name == "all":
+			return fmt.Errorf("status name '%s' is reserved", s.Name)
+		case seen[s.Name]:
+			return fmt.Errorf("status '%s' is defined twice", s.Name)
+		case s.Category != CategoryOpen && s.Category != CategoryClosed:
+			return fmt.Errorf("status '%s' has invalid category '%s' (must be: open, closed)", s.Name, s.Category)
+		}
+		seen[s.Name] = true
+	}
+
+	for _, required := range requiredStatuses {
+		if !seen[required] {
+			return fmt.Errorf("workflow must include the '%s' status", required)
+		}
+	}
+
+	for from, targets := range w.Transitions {
+		if !seen[from] {
+			return fmt.Errorf("transitions from unknown status '%s'", from)
+		}
+		for _, to := range targets {
+			if !seen[to] {
+				return fmt.Errorf("transition from '%s' to unknown status '%s'", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// Status returns the definition of a status
+func (w *Workflow) Status(name Status) (WorkflowStatus, bool) {
+	for _, s := range w.Statuses {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
+
+// AllowedTransitions returns the statuses a task may move to from a status,
+// and false if it may move to any status
+func (w *Workflow) AllowedTransitions(from Status) ([]Status, bool) {
+	targets, ok := w.Transitions[from]
+	return targets, ok
+}
+
+// CanTransition reports whether a task may move from one status to another.
+// Staying in the same status is always allowed.
+func (w *Workflow) CanTransition(from, to Status) bool {
+	targets, restricted := w.AllowedTransitions(from)
+	return from == to || !restricted || slices.Contains(targets, to)
+}
+
+// IsClosedStatus reports whether a status is in the closed category, meaning
+// the task's work is finished
+func IsClosedStatus(s Status) bool {
+	status, ok := workflow.Status(s)
+	return ok && status.Category == CategoryClosed
+}
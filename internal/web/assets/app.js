@@ -0,0 +1,930 @@
+let tasks = [];
+let currentView = 'board';
+let openTaskId = null;
+let currentTask = null;
+let editing = false;
+let newTaskStatus = null;
+let newTaskDraft = '';
+let eventSource = null;
+let pollTimer = null;
+let workflow = { statuses: ['backlog', 'next', 'active', 'blocked', 'done', 'cancelled'].map(name => ({ name: name })) };
+let wipUsage = {};
+
+// Send a JSON request to the API, throwing the server's error on failure.
+// Writes to an existing task pass the task so its ETag is sent as If-Match.
+async function apiRequest(method, url, body, task) {
+    const options = { method: method, headers: {} };
+    if (body !== undefined) {
+        options.headers['Content-Type'] = 'application/json';
+        options.body = JSON.stringify(body);
+    }
+    if (task) {
+        options.headers['If-Match'] = taskETag(task);
+    }
+
+    const response = await fetch(url, options);
+    const data = await response.json().catch(() => ({}));
+    if (!response.ok) {
+        const error = new Error(data.error ? data.error.message : response.statusText);
+        error.code = data.error ? data.error.code : '';
+        throw error;
+    }
+    return data;
+}
+
+// Matches the server's ETag: task ID plus the JSON "updated" timestamp
+function taskETag(task) {
+    return '"' + task.id + '-' + task.updated + '"';
+}
+
+function showError(message) {
+    const toast = document.getElementById('errorToast');
+    toast.textContent = message;
+    toast.style.display = 'block';
+    clearTimeout(showError.timer);
+    showError.timer = setTimeout(() => { toast.style.display = 'none'; }, 5000);
+}
+
+// Copy index fields from a full task into the board's task list
+function updateLocalEntry(task) {
+    const entry = tasks.find(t => t.id === task.id);
+    if (entry) {
+        entry.status = task.status;
+        entry.title = task.title;
+        entry.updated = task.updated;
+        renderTasks();
+    }
+}
+
+// Load the repository's statuses, which become the board columns. Statuses
+// with a configured color use it; the built-in ones are colored by style.css.
+async function loadWorkflow() {
+    try {
+        const response = await fetch('/api/workflow');
+        if (!response.ok) {
+            throw new Error(response.statusText);
+        }
+        workflow = await response.json();
+        workflow.statuses.forEach(s => {
+            if (s.color) statusColors[s.name] = s.color;
+        });
+        renderTasks();
+    } catch (error) {
+        console.error('Failed to load workflow:', error);
+    }
+}
+
+// Configured color of a status, or '' to leave it to the stylesheet
+function customStatusColor(status) {
+    const s = workflow.statuses.find(s => s.name === status);
+    return s && s.color ? s.color : '';
+}
+
+// "in-review" is shown as "In review"
+function statusName(status) {
+    const name = status.replace(/[-_]/g, ' ');
+    return name.charAt(0).toUpperCase() + name.slice(1);
+}
+
+// Search and note kind filters are applied by the server
+function hasServerFilters() {
+    return document.getElementById('searchBox').value.trim() !== '' ||
+        document.getElementById('kindFilter').value !== '';
+}
+
+async function loadTasks() {
+    const params = new URLSearchParams({ limit: '500' });
+    const query = document.getElementById('searchBox').value.trim();
+    const kind = document.getElementById('kindFilter').value;
+    if (query) params.set('q', query);
+    if (kind) params.set('note_kind', kind);
+
+    try {
+        // Follow the cursor until every matching task is loaded
+        const loaded = [];
+        let cursor = '';
+        do {
+            if (cursor) params.set('cursor', cursor);
+            const response = await fetch('/api/tasks?' + params);
+            if (!response.ok) {
+                throw new Error(response.statusText);
+            }
+            const page = await response.json();
+            loaded.push(...page.tasks);
+            cursor = page.next_cursor || '';
+        } while (cursor);
+
+        tasks = loaded;
+        await loadWIPUsage();
+        renderTasks();
+    } catch (error) {
+        console.error('Failed to load tasks:', error);
+    }
+}
+
+// Load WIP limit usage by status. It is counted by the server, since the
+// board may be filtered and doesn't know who moved each task.
+async function loadWIPUsage() {
+    try {
+        const response = await fetch('/api/wip');
+        if (!response.ok) {
+            throw new Error(response.statusText);
+        }
+        const data = await response.json();
+        wipUsage = {};
+        data.wip_limits.forEach(u => { wipUsage[u.status] = u; });
+    } catch (error) {
+        console.error('Failed to load WIP limits:', error);
+    }
+}
+
+// Column header count: "2", or with WIP limits "2/3" and "claude 1/1"
+function wipHeaderText(status, shown) {
+    const usage = wipUsage[status];
+    if (!usage) {
+        return String(shown);
+    }
+    const count = usage.count || 0;
+    let text = usage.limit ? count + '/' + usage.limit : String(count);
+    if (usage.agent_limit) {
+        text += ', ' + usage.agent + ' ' + (usage.agent_count || 0) + '/' + usage.agent_limit;
+    }
+    return text;
+}
+
+// Describes the WIP limit a column has reached, or returns ''
+function wipLimitReached(status) {
+    const usage = wipUsage[status];
+    if (!usage) {
+        return '';
+    }
+    if (usage.limit && (usage.count || 0) >= usage.limit) {
+        return 'WIP limit of ' + usage.limit + ' reached';
+    }
+    if (usage.agent_limit && (usage.agent_count || 0) >= usage.agent_limit) {
+        return usage.agent + ' has reached the limit of ' + usage.agent_limit + ' tasks here';
+    }
+    return '';
+}
+
+// Reload shortly after the user stops typing
+function scheduleSearch() {
+    clearTimeout(scheduleSearch.timer);
+    scheduleSearch.timer = setTimeout(loadTasks, 250);
+}
+
+function renderTasks() {
+    if (currentView === 'board') {
+        renderBoard();
+    } else if (currentView === 'list') {
+        renderList();
+    } else {
+        scheduleGraphLoad();
+    }
+}
+
+function setView(view) {
+    currentView = view;
+
+    document.getElementById('board').style.display = view === 'board' ? 'flex' : 'none';
+    document.getElementById('listView').style.display = view === 'list' ? 'block' : 'none';
+    document.getElementById('graphView').style.display = view === 'graph' ? 'block' : 'none';
+    ['board', 'list', 'graph'].forEach(v => {
+        document.getElementById(v + 'ViewBtn').classList.toggle('active', v === view);
+    });
+
+    if (view === 'board') {
+        renderBoard();
+    } else if (view === 'list') {
+        renderList();
+    } else {
+        loadGraph();
+    }
+}
+
+const statusColors = {
+    'backlog': '#9e9e9e',
+    'next': '#FFC107',
+    'active': '#2196F3',
+    'blocked': '#ff9800',
+    'done': '#4caf50',
+    'cancelled': '#f44336',
+    'label': '#9c27b0'
+};
+
+let graphPositions = {};
+
+// Open the graph view centered on a task ('' shows every linked task)
+function focusGraph(id) {
+    document.getElementById('graphFocus').value = id;
+    closeModal();
+    setView('graph');
+}
+
+// Coalesce reloads triggered by bursts of task changes
+function scheduleGraphLoad() {
+    clearTimeout(scheduleGraphLoad.timer);
+    scheduleGraphLoad.timer = setTimeout(loadGraph, 300);
+}
+
+async function loadGraph() {
+    const params = new URLSearchParams();
+    const focus = document.getElementById('graphFocus').value;
+    if (focus) {
+        params.set('focus', focus);
+        params.set('depth', document.getElementById('graphDepth').value);
+    }
+    if (document.getElementById('graphLabels').checked) {
+        params.set('labels', 'true');
+    }
+
+    try {
+        const graph = await apiRequest('GET', '/api/graph?' + params);
+        renderGraph(graph);
+    } catch (error) {
+        showError('Could not load graph: ' + error.message);
+    }
+}
+
+// Place nodes with a simple force simulation: nodes repel each other,
+// edges pull their ends together. Known nodes keep their positions so
+// live updates don't reshuffle the graph.
+function layoutGraph(nodes, edges, width, height) {
+    const pos = {};
+    nodes.forEach((node, i) => {
+        const angle = 2 * Math.PI * i / nodes.length;
+        pos[node.id] = graphPositions[node.id] ||
+            { x: width / 2 + Math.cos(angle) * width / 3, y: height / 2 + Math.sin(angle) * height / 3 };
+    });
+
+    const iterations = nodes.every(n => graphPositions[n.id]) ? 30 : 300;
+    for (let step = 0; step < iterations; step++) {
+        const force = {};
+        nodes.forEach(n => { force[n.id] = { x: 0, y: 0 }; });
+
+        for (let i = 0; i < nodes.length; i++) {
+            for (let j = i + 1; j < nodes.length; j++) {
+                const a = pos[nodes[i].id], b = pos[nodes[j].id];
+                let dx = a.x - b.x, dy = a.y - b.y;
+                const dist2 = Math.max(dx * dx + dy * dy, 100);
+                const push = 20000 / dist2;
+                const dist = Math.sqrt(dist2);
+                dx = dx / dist * push;
+                dy = dy / dist * push;
+                force[nodes[i].id].x += dx; force[nodes[i].id].y += dy;
+                force[nodes[j].id].x -= dx; force[nodes[j].id].y -= dy;
+            }
+        }
+
+        edges.forEach(edge => {
+            const a = pos[edge.source], b = pos[edge.target];
+            const dx = b.x - a.x, dy = b.y - a.y;
+            const dist = Math.max(Math.sqrt(dx * dx + dy * dy), 1);
+            const pull = (dist - 140) * 0.05;
+            force[edge.source].x += dx / dist * pull; force[edge.source].y += dy / dist * pull;
+            force[edge.target].x -= dx / dist * pull; force[edge.target].y -= dy / dist * pull;
+        });
+
+        const cooling = 1 - step / iterations;
+        nodes.forEach(n => {
+            const f = force[n.id];
+            f.x += (width / 2 - pos[n.id].x) * 0.01; // Gravity towards the center
+            f.y += (height / 2 - pos[n.id].y) * 0.01;
+            pos[n.id].x += Math.max(-20, Math.min(20, f.x)) * cooling;
+            pos[n.id].y += Math.max(-20, Math.min(20, f.y)) * cooling;
+        });
+    }
+
+    graphPositions = pos;
+    return pos;
+}
+
+function renderGraph(graph) {
+    const svg = document.getElementById('graphCanvas');
+    const width = svg.clientWidth || 1000;
+    const height = svg.clientHeight || 600;
+
+    if (graph.nodes.length === 0) {
+        svg.innerHTML = '<text x="20" y="30" fill="#999">No linked tasks</text>';
+        return;
+    }
+
+    const pos = layoutGraph(graph.nodes, graph.edges, width, height);
+
+    // Fit the layout into the canvas
+    const xs = graph.nodes.map(n => pos[n.id].x), ys = graph.nodes.map(n => pos[n.id].y);
+    const minX = Math.min(...xs) - 80, maxX = Math.max(...xs) + 80;
+    const minY = Math.min(...ys) - 40, maxY = Math.max(...ys) + 40;
+    svg.setAttribute('viewBox', minX + ' ' + minY + ' ' + (maxX - minX) + ' ' + (maxY - minY));
+
+    const edgeColors = { 'blocks': '#f44336', 'parent': '#9e9e9e' };
+    let html = '<defs>';
+    Object.keys(edgeColors).forEach(type => {
+        html += '<marker id="arrow-' + type + '" viewBox="0 0 10 10" refX="22" refY="5" markerWidth="6" markerHeight="6" orient="auto">';
+        html += '<path d="M0,0 L10,5 L0,10 z" fill="' + edgeColors[type] + '"/></marker>';
+    });
+    html += '</defs>';
+
+    graph.edges.forEach(edge => {
+        const a = pos[edge.source], b = pos[edge.target];
+        const color = edgeColors[edge.type] || '#2196F3';
+        html += '<line x1="' + a.x + '" y1="' + a.y + '" x2="' + b.x + '" y2="' + b.y + '" stroke="' + color + '" stroke-width="1.5"';
+        html += edgeColors[edge.type] ? ' marker-end="url(#arrow-' + edge.type + ')"' : ' stroke-dasharray="5,4"';
+        html += '/>';
+        html += '<text class="graph-edge-label" text-anchor="middle" x="' + (a.x + b.x) / 2 + '" y="' + ((a.y + b.y) / 2 - 4) + '">' +
+            escapeHtml(edge.label || edge.type) + '</text>';
+    });
+
+    graph.nodes.forEach(node => {
+        const p = pos[node.id];
+        const title = node.title.length > 30 ? node.title.substring(0, 27) + '...' : node.title;
+        html += '<g class="graph-node' + (node.id === graph.focus ? ' focus' : '') + '" data-id="' + node.id + '">';
+        html += '<title>#' + node.id + ' ' + escapeHtml(node.title) + ' (' + escapeHtml(node.status) + ')</title>';
+        html += '<circle cx="' + p.x + '" cy="' + p.y + '" r="12" fill="' + escapeHtml(statusColors[node.status] || '#999') + '"/>';
+        html += '<text x="' + (p.x + 16) + '" y="' + (p.y + 4) + '">#' + node.id + ' ' + escapeHtml(title) + '</text>';
+        html += '</g>';
+    });
+
+    svg.innerHTML = html;
+    svg.querySelectorAll('.graph-node').forEach(g => {
+        g.onclick = () => showTask(parseInt(g.dataset.id, 10));
+    });
+}
+
+function renderBoard() {
+    const statuses = workflow.statuses.map(s => s.name);
+
+    const board = document.getElementById('board');
+    board.innerHTML = '';
+
+    statuses.forEach(status => {
+        const column = document.createElement('div');
+        column.className = 'column';
+
+        // Drop target for moving tasks between statuses
+        column.ondragover = e => {
+            e.preventDefault();
+            column.classList.add('drag-over');
+        };
+        column.ondragleave = () => column.classList.remove('drag-over');
+        column.ondrop = e => {
+            e.preventDefault();
+            column.classList.remove('drag-over');
+            moveTask(parseInt(e.dataTransfer.getData('text/plain'), 10), status);
+        };
+
+        const header = document.createElement('div');
+        header.className = 'column-header';
+        const statusTasks = tasks.filter(t => t.status === status);
+
+        // Columns with a WIP limit show its usage, highlighted once reached
+        const headerText = document.createElement('span');
+        headerText.textContent = statusName(status) + ' (' + wipHeaderText(status, statusTasks.length) + ')';
+        const reached = wipLimitReached(status);
+        if (reached) {
+            header.classList.add('at-limit');
+            header.title = reached;
+        }
+        header.appendChild(headerText);
+
+        const addButton = document.createElement('button');
+        addButton.className = 'add-task-btn';
+        addButton.textContent = '+';
+        addButton.title = 'Create a task in ' + statusName(status);
+        addButton.onclick = () => openNewTaskInput(status);
+        header.appendChild(addButton);
+
+        column.appendChild(header);
+
+        if (newTaskStatus === status) {
+            column.appendChild(createNewTaskInput(status));
+        }
+
+        if (statusTasks.length === 0) {
+            const empty = document.createElement('div');
+            empty.className = 'empty-column';
+            empty.textContent = 'No tasks';
+            column.appendChild(empty);
+        } else {
+            statusTasks.forEach(task => {
+                const card = createTaskCard(task);
+                column.appendChild(card);
+            });
+        }
+
+        board.appendChild(column);
+    });
+
+    const input = document.getElementById('newTaskInput');
+    if (input) {
+        input.focus();
+        input.setSelectionRange(input.value.length, input.value.length);
+    }
+}
+
+function openNewTaskInput(status) {
+    newTaskStatus = status;
+    newTaskDraft = '';
+    renderBoard();
+}
+
+function createNewTaskInput(status) {
+    const input = document.createElement('input');
+    input.type = 'text';
+    input.id = 'newTaskInput';
+    input.className = 'new-task-input';
+    input.placeholder = 'Task title, Enter to create';
+    input.value = newTaskDraft;
+    input.oninput = () => { newTaskDraft = input.value; };
+    input.onkeydown = e => {
+        if (e.key === 'Enter' && input.value.trim() !== '') {
+            const title = input.value.trim();
+            newTaskStatus = null;
+            newTaskDraft = '';
+            createTaskInColumn(status, title);
+        } else if (e.key === 'Escape') {
+            newTaskStatus = null;
+            renderBoard();
+        }
+    };
+    return input;
+}
+
+// Optimistically add a task to a column, then create it on the server
+async function createTaskInColumn(status, title) {
+    const now = new Date().toISOString();
+    const placeholder = { id: -Date.now(), status: status, title: title, created: now, updated: now, pending: true };
+    tasks.push(placeholder);
+    renderTasks();
+
+    const removePlaceholder = () => {
+        const i = tasks.indexOf(placeholder);
+        if (i >= 0) tasks.splice(i, 1);
+    };
+
+    let created = null;
+    try {
+        created = (await apiRequest('POST', '/api/tasks', { title: title })).task;
+        if (status !== 'backlog') {
+            created = (await apiRequest('PATCH', '/api/task/' + created.id, { status: status }, created)).task;
+        }
+    } catch (error) {
+        removePlaceholder();
+        renderTasks();
+        showError('Could not create task: ' + error.message);
+        if (created) loadTasks(); // Created, but the status change failed
+        return;
+    }
+
+    removePlaceholder();
+    // The event stream may already have delivered the new task
+    if (!tasks.some(t => t.id === created.id)) {
+        tasks.push({ id: created.id, status: created.status, title: created.title, created: created.created, updated: created.updated });
+    }
+    renderTasks();
+}
+
+// Optimistically move a task to another status, rolling back on error. A
+// move past a WIP limit is only made after the user confirms it.
+async function moveTask(id, status, force) {
+    const entry = tasks.find(t => t.id === id);
+    if (!entry || entry.pending || entry.status === status) {
+        return;
+    }
+
+    const previous = entry.status;
+    entry.status = status;
+    renderTasks();
+
+    try {
+        const body = { status: status };
+        if (force) body.force = true;
+        const result = await apiRequest('PATCH', '/api/task/' + id, body, entry);
+        updateLocalEntry(result.task);
+    } catch (error) {
+        entry.status = previous;
+        renderTasks();
+        if (error.code === 'wip_limit' && confirm(error.message + '\n\nMove #' + id + ' anyway? The override is noted on the task.')) {
+            moveTask(id, status, true);
+            return;
+        }
+        showError('Could not move #' + id + ': ' + error.message);
+        if (error.code === 'conflict') loadTasks();
+    }
+}
+
+function createTaskCard(task) {
+    const card = document.createElement('div');
+    card.className = 'task-card status-' + task.status;
+    const color = customStatusColor(task.status);
+    if (color) {
+        card.style.borderLeft = '4px solid ' + color;
+    }
+
+    if (task.pending) {
+        card.classList.add('pending');
+    } else {
+        card.onclick = () => showTask(task.id);
+        card.draggable = true;
+        card.ondragstart = e => {
+            e.dataTransfer.setData('text/plain', String(task.id));
+            card.classList.add('dragging');
+        };
+        card.ondragend = () => card.classList.remove('dragging');
+    }
+
+    const id = document.createElement('div');
+    id.className = 'task-id';
+    id.textContent = task.pending ? 'Saving…' : '#' + task.id;
+
+    const title = document.createElement('div');
+    title.className = 'task-title';
+    title.textContent = task.title;
+
+    const date = document.createElement('div');
+    date.className = 'task-date';
+    date.textContent = formatDate(task.updated);
+
+    card.appendChild(id);
+    card.appendChild(title);
+    card.appendChild(date);
+
+    return card;
+}
+
+async function showTask(id) {
+    try {
+        const response = await fetch('/api/task/' + id);
+        const task = await response.json();
+        currentTask = task;
+        editing = false;
+        renderTaskDetail(task);
+        openTaskId = id;
+        document.getElementById('taskModal').style.display = 'block';
+    } catch (error) {
+        console.error('Failed to load task:', error);
+    }
+}
+
+function renderTaskDetail(task) {
+    const detail = document.getElementById('taskDetail');
+
+    let html = '<div class="task-detail">';
+    html += '<h2 id="titleHeading"><span class="editable" onclick="editTitle()" title="Click to edit">#' + task.id + ': ' + escapeHtml(task.title) + '</span></h2>';
+
+    html += '<div class="task-meta">';
+    html += '<div class="meta-item"><div class="meta-label">Status</div><div class="meta-value">' + escapeHtml(task.status) + '</div></div>';
+    html += '<div class="meta-item"><div class="meta-label">Created</div><div class="meta-value">' + formatDate(task.created) + '</div></div>';
+    html += '<div class="meta-item"><div class="meta-label">Updated</div><div class="meta-value">' + formatDate(task.updated) + '</div></div>';
+    if (task.due) {
+        html += '<div class="meta-item"><div class="meta-label">Due</div><div class="meta-value">' + escapeHtml(task.due) + '</div></div>';
+    }
+    if (task.completed) {
+        html += '<div class="meta-item"><div class="meta-label">Completed</div><div class="meta-value">' + formatDate(task.completed) + '</div></div>';
+    }
+    if (task.tags && task.tags.length > 0) {
+        html += '<div class="meta-item"><div class="meta-label">Tags</div><div class="meta-value">' + task.tags.map(escapeHtml).join(', ') + '</div></div>';
+    }
+    html += '</div>';
+
+    html += '<div class="section">';
+    html += '<div class="section-title">Description</div>';
+    html += '<div id="descriptionBlock">';
+    if (task.description) {
+        // Rendered (and sanitized) by the server; plain text while a change is saving
+        const description = task.description_html !== undefined ? task.description_html : escapeHtml(task.description);
+        const className = task.description_html !== undefined ? 'description markdown' : 'description plain';
+        html += '<div class="' + className + ' editable" onclick="editDescription(event)" title="Click to edit">' + description + '</div>';
+    } else {
+        html += '<div class="description editable placeholder" onclick="editDescription(event)">Click to add a description</div>';
+    }
+    html += '</div></div>';
+
+    if (task.links && task.links.length > 0) {
+        html += '<div class="section">';
+        html += '<div class="section-title">Links</div>';
+        html += '<ul class="links-list">';
+        task.links.forEach(link => {
+            html += '<li class="link-item">';
+            html += '<span class="link-type">' + escapeHtml(link.type) + '</span> #' + link.target_id;
+            if (link.label) {
+                html += ' <em>(' + escapeHtml(link.label) + ')</em>';
+            }
+            html += '</li>';
+        });
+        html += '</ul>';
+        html += '<button class="btn" onclick="focusGraph(' + task.id + ')">Show in graph</button>';
+        html += '</div>';
+    }
+
+    html += '<div class="section">';
+    html += '<div class="section-title">Notes</div>';
+    if (task.notes && task.notes.length > 0) {
+        html += '<ul class="notes-list">';
+        task.notes.forEach((note, i) => {
+            html += '<li class="note-item">';
+            html += '<div class="note-meta">' + formatDate(note.timestamp) + ' - ' + escapeHtml(note.author);
+            if (note.kind) {
+                html += '<span class="note-kind ' + escapeHtml(note.kind) + '">' + escapeHtml(note.kind) + '</span>';
+            }
+            html += '</div>';
+            if (task.notes_html && task.notes_html[i] !== undefined) {
+                html += '<div class="note-text markdown">' + task.notes_html[i] + '</div>';
+            } else {
+                html += '<div class="note-text plain">' + escapeHtml(note.text) + '</div>';
+            }
+            html += '</li>';
+        });
+        html += '</ul>';
+    }
+    html += '<textarea id="noteInput" class="note-input" placeholder="Add a note..." onfocus="editing = true" onblur="editing = false"></textarea>';
+    html += '<div class="note-form">';
+    html += '<select id="noteKind" class="kind-filter">';
+    html += '<option value="">Note</option><option value="decision">Decision</option><option value="question">Question</option>';
+    html += '<option value="blocker">Blocker</option><option value="progress">Progress</option>';
+    html += '</select>';
+    html += '<button class="btn btn-primary" onclick="addNote()">Add note</button>';
+    html += '</div></div>';
+
+    html += '</div>';
+    detail.innerHTML = html;
+}
+
+function editTitle() {
+    editing = true;
+    const heading = document.getElementById('titleHeading');
+    heading.innerHTML = '<input type="text" id="titleInput" class="edit-input">';
+    const input = document.getElementById('titleInput');
+    input.value = currentTask.title;
+    input.focus();
+    input.onkeydown = e => {
+        if (e.key === 'Enter') input.blur();
+        if (e.key === 'Escape') {
+            input.onblur = null;
+            editing = false;
+            renderTaskDetail(currentTask);
+        }
+    };
+    input.onblur = () => saveField('title', input.value.trim());
+}
+
+function editDescription(event) {
+    if (event && event.target.closest('a, input')) {
+        return; // Let links and checkboxes in the rendered text work
+    }
+    editing = true;
+    const block = document.getElementById('descriptionBlock');
+    block.innerHTML = '<textarea id="descriptionInput" class="edit-textarea"></textarea>' +
+        '<div class="edit-actions">' +
+        '<button class="btn btn-primary" onclick="saveField(\'description\', document.getElementById(\'descriptionInput\').value)">Save</button>' +
+        '<button class="btn" onclick="editing = false; renderTaskDetail(currentTask)">Cancel</button>' +
+        '</div>';
+    const textarea = document.getElementById('descriptionInput');
+    textarea.value = currentTask.description || '';
+    textarea.focus();
+}
+
+// Optimistically change a field of the open task, rolling back on error
+async function saveField(field, value) {
+    editing = false;
+    const task = currentTask;
+    const previous = task[field];
+
+    if (value === previous || (field === 'title' && value === '')) {
+        renderTaskDetail(task);
+        return;
+    }
+
+    const previousHtml = task.description_html;
+    task[field] = value;
+    if (field === 'description') {
+        delete task.description_html; // Stale until the server renders it
+    }
+    renderTaskDetail(task);
+    updateLocalEntry(task);
+
+    try {
+        const result = await apiRequest('PATCH', '/api/task/' + task.id, { [field]: value }, task);
+        updateLocalEntry(result.task);
+        if (currentTask === task) {
+            showTask(task.id); // Fetch the rendered version
+        }
+    } catch (error) {
+        task[field] = previous;
+        task.description_html = previousHtml;
+        if (currentTask === task) renderTaskDetail(task);
+        updateLocalEntry(task);
+        showError('Could not save ' + field + ': ' + error.message);
+        if (error.code === 'conflict' && currentTask === task) showTask(task.id);
+    }
+}
+
+// Optimistically append a note to the open task, rolling back on error
+async function addNote() {
+    const text = document.getElementById('noteInput').value.trim();
+    const kind = document.getElementById('noteKind').value;
+    if (text === '') {
+        return;
+    }
+
+    editing = false;
+    const task = currentTask;
+    const note = { timestamp: new Date().toISOString(), author: '…', text: text, kind: kind };
+    task.notes = task.notes || [];
+    task.notes.push(note);
+    renderTaskDetail(task);
+
+    const body = { note: text };
+    if (kind) body.note_kind = kind;
+
+    try {
+        const result = await apiRequest('PATCH', '/api/task/' + task.id, body, task);
+        updateLocalEntry(result.task);
+        if (currentTask === task) {
+            showTask(task.id); // Fetch the rendered version
+        }
+    } catch (error) {
+        task.notes.splice(task.notes.indexOf(note), 1);
+        if (currentTask === task) {
+            renderTaskDetail(task);
+            document.getElementById('noteInput').value = text;
+        }
+        showError('Could not add note: ' + error.message);
+        if (error.code === 'conflict' && currentTask === task) {
+            await showTask(task.id);
+            document.getElementById('noteInput').value = text;
+        }
+    }
+}
+
+function closeModal() {
+    openTaskId = null;
+    currentTask = null;
+    editing = false;
+    document.getElementById('taskModal').style.display = 'none';
+}
+
+function formatDate(dateStr) {
+    const date = new Date(dateStr);
+    return date.toLocaleString();
+}
+
+// Escape text for use in HTML, including inside quoted attribute values
+function escapeHtml(text) {
+    const entities = { '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' };
+    return String(text ?? '').replace(/[&<>"']/g, c => entities[c]);
+}
+
+function renderList() {
+    const listView = document.getElementById('listView');
+    listView.innerHTML = '';
+
+    if (tasks.length === 0) {
+        listView.innerHTML = '<div class="empty-column">No tasks found</div>';
+        return;
+    }
+
+    // Sort by ID descending (newest first)
+    const sortedTasks = [...tasks].sort((a, b) => b.id - a.id);
+
+    sortedTasks.forEach(task => {
+        const taskDiv = document.createElement('div');
+        taskDiv.className = 'list-task';
+        taskDiv.onclick = () => showTask(task.id);
+
+        const header = document.createElement('div');
+        header.className = 'list-task-header';
+
+        const statusSpan = document.createElement('span');
+        statusSpan.className = 'list-task-status ' + task.status;
+        statusSpan.textContent = task.status;
+        const color = customStatusColor(task.status);
+        if (color) {
+            statusSpan.style.background = color;
+        }
+
+        const idSpan = document.createElement('span');
+        idSpan.className = 'list-task-id';
+        idSpan.textContent = '#' + task.id;
+
+        const titleSpan = document.createElement('span');
+        titleSpan.className = 'list-task-title';
+        titleSpan.textContent = task.title;
+
+        const dateSpan = document.createElement('span');
+        dateSpan.className = 'list-task-date';
+        dateSpan.textContent = formatDate(task.updated);
+
+        header.appendChild(statusSpan);
+        header.appendChild(idSpan);
+        header.appendChild(titleSpan);
+        header.appendChild(dateSpan);
+
+        taskDiv.appendChild(header);
+        listView.appendChild(taskDiv);
+    });
+}
+
+// Close modal when clicking outside
+window.onclick = function(event) {
+    const modal = document.getElementById('taskModal');
+    if (event.target == modal) {
+        closeModal();
+    }
+}
+
+// Open tasks referenced as #123 in rendered Markdown
+document.getElementById('taskDetail').addEventListener('click', event => {
+    const ref = event.target.closest('a.task-ref');
+    if (ref) {
+        event.preventDefault();
+        event.stopPropagation();
+        showTask(parseInt(ref.dataset.task, 10));
+    }
+});
+
+// Apply a single task change pushed by the server
+function applyTaskEvent(event) {
+    // Filtered results depend on full task data; just reload
+    if (hasServerFilters()) {
+        loadTasks();
+        return;
+    }
+
+    const i = tasks.findIndex(t => t.id === event.id);
+    if (event.type === 'task-deleted') {
+        if (i >= 0) tasks.splice(i, 1);
+    } else if (i >= 0) {
+        tasks[i] = event.task;
+    } else {
+        tasks.push(event.task);
+    }
+    renderTasks();
+
+    if (openTaskId === event.id && event.type !== 'task-deleted' && !editing) {
+        showTask(event.id);
+    }
+}
+
+function setLive(live) {
+    const indicator = document.getElementById('liveIndicator');
+    indicator.className = live ? 'live-indicator live' : 'live-indicator';
+    indicator.textContent = live ? '● Live' : '○ Polling';
+    indicator.title = live ? 'Receiving changes as they happen' : 'Polling every 5 seconds';
+}
+
+function startPolling() {
+    setLive(false);
+    if (!pollTimer) {
+        pollTimer = setInterval(loadTasks, 5000);
+    }
+}
+
+function stopPolling() {
+    setLive(true);
+    if (pollTimer) {
+        clearInterval(pollTimer);
+        pollTimer = null;
+    }
+}
+
+// Subscribe to server-sent events, falling back to polling while the
+// stream is down (EventSource reconnects on its own)
+function connectEvents() {
+    if (!window.EventSource) {
+        startPolling();
+        return;
+    }
+
+    eventSource = new EventSource('/api/events');
+    eventSource.addEventListener('ready', () => {
+        stopPolling();
+        loadTasks(); // Resync anything missed while disconnected
+    });
+    eventSource.addEventListener('task-changed', e => applyTaskEvent(JSON.parse(e.data)));
+    eventSource.addEventListener('task-deleted', e => applyTaskEvent(JSON.parse(e.data)));
+    eventSource.onerror = () => {
+        startPolling();
+        if (eventSource.readyState === EventSource.CLOSED) {
+            setTimeout(connectEvents, 5000);
+        }
+    };
+}
+
+// Open a task linked as /#task-42 (used by the Atom and calendar feeds)
+function showTaskFromHash() {
+    const match = location.hash.match(/^#task-(\d+)$/);
+    if (match) {
+        showTask(parseInt(match[1], 10));
+    }
+}
+window.addEventListener('hashchange', showTaskFromHash);
+
+// Load tasks on page load
+loadWorkflow();
+loadTasks();
+showTaskFromHash();
+
+// Live updates, with polling as fallback
+startPolling();
+connectEvents();
@@ -0,0 +1,45 @@
+// Package web holds the web UI served by "task serve": index.html, the
+// style.css and app.js it loads, and an empty theme.css that custom UI
+// directories can replace to restyle the board.
+package web
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Assets returns the embedded UI files
+func Assets() fs.FS {
+	sub, err := fs.Sub(assets, "assets")
+	if err != nil {
+		panic(err) // The directory is embedded at build time
+	}
+	return sub
+}
+
+// Overlay returns a file system that serves files from upper, falling back to
+// lower for files upper doesn't have. It lets a custom UI directory override
+// single files while the rest come from the embedded UI.
+func Overlay(upper, lower fs.FS) fs.FS {
+	return overlayFS{upper: upper, lower: lower}
+}
+
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.lower.Open(name)
+}
@@ -3,24 +3,32 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/onuse/tasks/internal/commands"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	args, jsonMode, agent := parseGlobalFlags(os.Args[1:])
+	commands.SetJSONOutput(jsonMode)
+	commands.SetAgent(agent)
+
+	if len(args) < 1 {
+		if jsonMode {
+			os.Exit(commands.ReportError("", commands.UsageError()))
+		}
 		printUsage()
 		os.Exit(1)
 	}
 
-	command := os.Args[1]
-	args := os.Args[2:]
+	command := args[0]
+	args = args[1:]
 
 	var err error
 
 	switch command {
 	case "init":
-		err = commands.Init()
+		err = commands.Init(args)
 	case "create":
 		err = commands.Create(args)
 	case "list":
@@ -43,27 +51,83 @@ func main() {
 		err = commands.Search(args)
 	case "context":
 		err = commands.Context(args)
+	case "session":
+		err = commands.Session(args)
+	case "sessions":
+		err = commands.Sessions(args)
+	case "handoff":
+		err = commands.Handoff(args)
+	case "decisions":
+		err = commands.Decisions(args)
+	case "export":
+		err = commands.Export(args)
+	case "import":
+		err = commands.Import(args)
+	case "scan":
+		err = commands.Scan(args)
+	case "git-log":
+		err = commands.GitLog(args)
+	case "hooks":
+		err = commands.Hooks(args)
+	case "merge-driver":
+		err = commands.MergeDriver(args)
+	case "index-cache":
+		err = commands.IndexCache(args)
+	case "archive":
+		err = commands.Archive(args)
+	case "unarchive":
+		err = commands.Unarchive(args)
+	case "webhook":
+		err = commands.Webhook(args)
 	case "serve":
 		err = commands.Serve(args)
 	default:
-		fmt.Fprintf(os.Stderr, "Error: unknown command '%s'\n", command)
-		printUsage()
-		os.Exit(1)
+		code := commands.ReportError(command, commands.UnknownCommandError(command))
+		if !jsonMode {
+			printUsage()
+		}
+		os.Exit(code)
 	}
 
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(commands.ReportError(command, err))
+	}
+}
+
+// parseGlobalFlags strips the global flags before the command name and
+// returns the command and its arguments. Scanning stops at the command (or
+// "--"), so a command's own arguments are never taken for global flags;
+// commands with options accept --json among them too (see newFlagSet).
+func parseGlobalFlags(args []string) (rest []string, jsonMode bool, agent string) {
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		switch {
+		case arg == "--json" || arg == "-json":
+			jsonMode = true
+		case (arg == "--agent" || arg == "-agent") && i+1 < len(args):
+			agent = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--agent="):
+			agent = strings.TrimPrefix(arg, "--agent=")
+		default:
+			return args[i:], jsonMode, agent
+		}
 	}
+	return args[i:], jsonMode, agent
 }
 
 func printUsage() {
-	fmt.Println("Usage: task <command> [options]")
+	fmt.Println("Usage: task [--json] [--agent NAME] <command> [options]")
 	fmt.Println("\nCommands:")
-	fmt.Println("  init                           Initialize task tracking in current repository")
+	fmt.Println("  init [--index-cache]           Initialize task tracking in current repository")
 	fmt.Println("  create <title> [description]   Create a new task")
 	fmt.Println("  list [--status STATUS]         List tasks (defaults to active)")
-	fmt.Println("  show <id>                      Show full task details")
+	fmt.Println("  show <id> [--render]           Show full task details")
 	fmt.Println("  update <id> [options]          Update a task")
 	fmt.Println("  link <id> <target> [options]   Link two tasks together")
 	fmt.Println("  unlink <id> <target> [options] Remove link between tasks")
@@ -71,6 +135,23 @@ func printUsage() {
 	fmt.Println("  untag <id> <name>              Remove a tag from a task")
 	fmt.Println("  merge <source> <target>        Merge source task into target")
 	fmt.Println("  search <query> [options]       Search tasks by keyword")
+	fmt.Println("  archive --older-than AGE       Move old done/cancelled tasks to the archive")
+	fmt.Println("  unarchive <id>...              Restore archived tasks")
+	fmt.Println("  decisions                      List decision notes across all tasks")
 	fmt.Println("  context                        Show project context for LLMs")
-	fmt.Println("  serve [--port PORT]            Start web UI server")
+	fmt.Println("  session <start|end|show>       Start, end or show the current agent session")
+	fmt.Println("  sessions [options]             List agent sessions")
+	fmt.Println("  handoff [options]              Summarize the agent's session for the next agent")
+	fmt.Println("  export [options]               Export tasks as CSV, Markdown, JSONL or HTML")
+	fmt.Println("  import --from SOURCE <file>    Import GitHub/GitLab issues or a checklist")
+	fmt.Println("  scan [paths] [--markers LIST]  Create tasks from TODO/FIXME/HACK comments")
+	fmt.Println("  git-log <id> [--all]           Show commits that reference a task")
+	fmt.Println("  hooks <install|uninstall>      Install git hooks that link commits to tasks")
+	fmt.Println("  merge-driver install           Merge .tasks files automatically in git merges")
+	fmt.Println("  index-cache <enable|disable>   Keep the index out of git in a rebuilt cache")
+	fmt.Println("  webhook <command> [options]    Add, list, remove, test or log webhooks")
+	fmt.Println("  serve [options]                Start web UI server")
+	fmt.Println("\nGlobal options:")
+	fmt.Println("  --json                         Emit a versioned JSON result object (and JSON errors)")
+	fmt.Println("  --agent NAME                   Identity recorded on writes (default: $TASK_AGENT or human)")
 }